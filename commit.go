@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// 事务结果的错误分类，使用errors.Is判断
+var (
+	// ErrRolledBack 提交前事务已被回滚，如超时或上下文取消；fn返回的错误原样返回，不属于此分类
+	ErrRolledBack = errors.New("transaction rolled back")
+	// ErrCommitFailed 数据库明确拒绝了提交，事务没有生效
+	ErrCommitFailed = errors.New("failed to commit transaction")
+	// ErrCommitUnknown 提交过程中连接中断，无法确定事务是否已提交
+	ErrCommitUnknown = errors.New("transaction commit outcome unknown")
+)
+
+// rollbackError 提交前事务已被回滚导致的提交错误
+// 错误信息与包装的错误相同，同时满足errors.Is(err, ErrRolledBack)
+type rollbackError struct {
+	err error
+}
+
+// Error 返回错误信息
+// 返回值:
+//   - string: 错误信息
+func (e *rollbackError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap 返回回滚分类和原始错误
+// 返回值:
+//   - []error: 错误列表
+func (e *rollbackError) Unwrap() []error {
+	return []error{ErrRolledBack, e.err}
+}
+
+// commitOutcome 提交错误的分类
+type commitOutcome int
+
+const (
+	// commitRolledBack 提交前事务已回滚
+	commitRolledBack commitOutcome = iota
+	// commitFailed 提交被明确拒绝
+	commitFailed
+	// commitUnknown 提交结果未知
+	commitUnknown
+	// commitCommitted 通过幂等键确认事务已提交
+	commitCommitted
+)
+
+// classifyCommit 判断提交错误后事务的状态
+// 参数:
+//   - dbType: 数据库类型
+//   - err: 提交错误
+// 返回值:
+//   - commitOutcome: 提交错误的分类
+func classifyCommit(dbType string, err error) commitOutcome {
+	// 上下文结束后database/sql会回滚事务，提交不会发送到数据库
+	if errors.Is(err, sql.ErrTxDone) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return commitRolledBack
+	}
+	// PostgreSQL中已出错的事务提交时会被回滚
+	if errors.Is(err, pgx.ErrTxCommitRollback) {
+		return commitRolledBack
+	}
+
+	// 数据库返回了错误响应，提交没有生效
+	var mysqlErr *mysqldriver.MySQLError
+	var pgErr *pgconn.PgError
+	if errors.As(err, &mysqlErr) || errors.As(err, &pgErr) {
+		return commitFailed
+	}
+	// 驱动约定只在请求未发出时返回ErrBadConn，断开连接后数据库会回滚未提交的事务
+	if errors.Is(err, driver.ErrBadConn) || pgconn.SafeToRetry(err) {
+		return commitFailed
+	}
+	// SQLite在进程内提交，没有连接中断的问题
+	if dialectFamily(dbType) == "sqlite" {
+		return commitFailed
+	}
+	return commitUnknown
+}
+
+// commitError 按分类包装提交错误
+// 参数:
+//   - outcome: 提交错误的分类
+//   - err: 提交错误
+// 返回值:
+//   - error: 包装后的错误
+func commitError(outcome commitOutcome, err error) error {
+	switch outcome {
+	case commitRolledBack:
+		return &rollbackError{err: fmt.Errorf("failed to commit transaction: %w", err)}
+	case commitFailed:
+		return fmt.Errorf("%w: %w", ErrCommitFailed, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrCommitUnknown, err)
+	}
+}
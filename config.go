@@ -1,12 +1,23 @@
 package database
 
-import "time"
+import (
+	"io"
+	"time"
+)
 
 // Config 数据库配置结构体
 // 包含主从数据库配置、连接池配置、日志配置和慢查询配置
 type Config struct {
 	// 主库配置
 	Master string `json:"master" yaml:"master" mapstructure:"master"`
+	// 主库结构化连接配置，未设置Master时用于生成DSN
+	Connection ConnectionConfig `json:"connection" yaml:"connection" mapstructure:"connection"`
+	// 主库凭据配置，设置后连接时动态获取密码
+	Credentials CredentialConfig `json:"credentials" yaml:"credentials" mapstructure:"credentials"`
+	// 主库每个新连接建立后执行的初始化语句，如时区、sql_mode、search_path、PRAGMA
+	InitStatements []string `json:"init_statements" yaml:"init_statements" mapstructure:"init_statements"`
+	// 主库TLS配置
+	TLS TLSConfig `json:"tls" yaml:"tls" mapstructure:"tls"`
 	// 从库配置
 	Slaves []SlaveConfig `json:"slaves" yaml:"slaves" mapstructure:"slaves"`
 	// 数据库类型 (mysql, postgres, sqlite等)
@@ -19,15 +30,25 @@ type Config struct {
 	SlowQueryConfig SlowQueryConfig `json:"slow_query_config" yaml:"slow_query_config" mapstructure:"slow_query_config"`
 	// 监控配置
 	MonitorConfig MonitorConfig `json:"monitor_config" yaml:"monitor_config" mapstructure:"monitor_config"`
+	// 事务配置
+	TransactionConfig TransactionConfig `json:"transaction_config" yaml:"transaction_config" mapstructure:"transaction_config"`
 }
 
 // SlaveConfig 从库配置结构体
 // 包含从库连接信息、权重和连接池配置
 type SlaveConfig struct {
 	DSN string `json:"dsn" yaml:"dsn" mapstructure:"dsn"`
+	// 从库结构化连接配置，未设置DSN时用于生成DSN
+	Connection ConnectionConfig `json:"connection" yaml:"connection" mapstructure:"connection"`
+	// 从库凭据配置，设置后连接时动态获取密码
+	Credentials CredentialConfig `json:"credentials" yaml:"credentials" mapstructure:"credentials"`
+	// 从库每个新连接建立后执行的初始化语句
+	InitStatements []string `json:"init_statements" yaml:"init_statements" mapstructure:"init_statements"`
+	// 从库TLS配置
+	TLS TLSConfig `json:"tls" yaml:"tls" mapstructure:"tls"`
 	// 数据库类型
 	Type string `json:"type" yaml:"type" mapstructure:"type"`
-	// 从库权重，用于负载均衡
+	// 从库权重，读请求按权重比例随机分配，0表示未设置，按1处理
 	Weight int `json:"weight" yaml:"weight" mapstructure:"weight"`
 	// 连接池配置
 	PoolConfig PoolConfig `json:"pool_config" yaml:"pool_config" mapstructure:"pool_config"`
@@ -36,13 +57,13 @@ type SlaveConfig struct {
 // PoolConfig 连接池配置结构体
 // 用于控制数据库连接池的各项参数
 type PoolConfig struct {
-	// 连接池最大连接数
+	// 连接池最大连接数，0表示不限制
 	MaxOpenConns int `json:"max_open_conns" yaml:"max_open_conns" mapstructure:"max_open_conns"`
-	// 连接池最大空闲连接数
+	// 连接池最大空闲连接数，0表示使用database/sql的默认值2
 	MaxIdleConns int `json:"max_idle_conns" yaml:"max_idle_conns" mapstructure:"max_idle_conns"`
-	// 连接池最大连接生命周期
+	// 连接池最大连接生命周期，0表示不限制
 	ConnMaxLifetime time.Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime" mapstructure:"conn_max_lifetime"`
-	// 连接池最大连接空闲时间
+	// 连接池最大连接空闲时间，0表示不限制
 	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time" yaml:"conn_max_idle_time" mapstructure:"conn_max_idle_time"`
 }
 
@@ -59,6 +80,36 @@ type LogConfig struct {
 	IgnoreRecordNotFoundError bool `json:"ignore_record_not_found_error" yaml:"ignore_record_not_found_error" mapstructure:"ignore_record_not_found_error"`
 	// 是否记录参数化查询
 	ParameterizedQueries bool `json:"parameterized_queries" yaml:"parameterized_queries" mapstructure:"parameterized_queries"`
+	// 敏感信息脱敏配置
+	Redact RedactConfig `json:"redact" yaml:"redact" mapstructure:"redact"`
+	// SQL日志采样配置
+	Sampling SamplingConfig `json:"sampling" yaml:"sampling" mapstructure:"sampling"`
+}
+
+// RedactConfig 敏感信息脱敏配置结构体
+// 对所有日志路径中的SQL生效，模型字段可通过 `gorm:"sensitive"` 标签标记为敏感
+type RedactConfig struct {
+	// 是否启用脱敏
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	// 敏感列名规则，不区分大小写的子串匹配 (如 password, token, email)
+	Columns []string `json:"columns" yaml:"columns" mapstructure:"columns"`
+	// 作用于SQL文本的正则表达式，包含捕获组时只替换捕获组内容
+	Patterns []string `json:"patterns" yaml:"patterns" mapstructure:"patterns"`
+	// 脱敏占位符，默认为 [REDACTED]
+	Placeholder string `json:"placeholder" yaml:"placeholder" mapstructure:"placeholder"`
+}
+
+// SamplingConfig SQL日志采样配置结构体
+// 按SQL指纹限流，执行出错和超过慢查询阈值的SQL始终记录
+type SamplingConfig struct {
+	// 是否启用采样
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	// 每个周期内每种SQL指纹先完整记录的条数
+	Initial int `json:"initial" yaml:"initial" mapstructure:"initial"`
+	// 超过Initial后每Thereafter条记录1条，为0时全部丢弃
+	Thereafter int `json:"thereafter" yaml:"thereafter" mapstructure:"thereafter"`
+	// 采样周期，默认1秒
+	Interval time.Duration `json:"interval" yaml:"interval" mapstructure:"interval"`
 }
 
 // SlowQueryConfig 慢查询配置结构体
@@ -68,8 +119,18 @@ type SlowQueryConfig struct {
 	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
 	// 慢查询阈值，超过此时间的查询将被记录
 	Threshold time.Duration `json:"threshold" yaml:"threshold" mapstructure:"threshold"`
-	// 是否记录查询参数
+	// 是否记录查询参数，关闭时只记录SQL指纹
 	LogParams bool `json:"log_params" yaml:"log_params" mapstructure:"log_params"`
+	// 慢查询日志文件路径，为空时写入标准输出
+	Output string `json:"output" yaml:"output" mapstructure:"output"`
+	// 慢查询日志输出，优先于Output
+	Writer io.Writer `json:"-" yaml:"-" mapstructure:"-"`
+	// 单个日志文件最大大小（MB），超过后轮转，0表示不限制
+	MaxSize int `json:"max_size" yaml:"max_size" mapstructure:"max_size"`
+	// 单个日志文件最长写入时间，超过后轮转，0表示不限制
+	MaxAge time.Duration `json:"max_age" yaml:"max_age" mapstructure:"max_age"`
+	// 保留的轮转文件数量，0表示全部保留
+	MaxBackups int `json:"max_backups" yaml:"max_backups" mapstructure:"max_backups"`
 }
 
 // MonitorConfig 监控配置结构体
@@ -84,3 +145,16 @@ type MonitorConfig struct {
 	// 最大重试次数
 	MaxRetries int `json:"max_retries" yaml:"max_retries" mapstructure:"max_retries"`
 }
+
+// TransactionConfig 事务配置结构体
+// 用于限制事务时长并发现长时间未结束的事务，重载配置后对新开启的事务生效
+type TransactionConfig struct {
+	// 默认事务超时时间，超时后取消事务上下文并回滚，可被TxOptions.Timeout覆盖，0表示不限制
+	Timeout time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	// 长事务阈值，事务运行超过此时间时记录警告日志（包含开启事务的调用栈），0表示不检测
+	LongRunningThreshold time.Duration `json:"long_running_threshold" yaml:"long_running_threshold" mapstructure:"long_running_threshold"`
+	// 发现长事务时的回调，可用于上报事件或告警
+	OnLongRunning func(info TransactionInfo) `json:"-" yaml:"-" mapstructure:"-"`
+	// 每个最外层事务结束时的回调，可用于对接指标和链路追踪系统
+	OnFinish func(event TransactionEvent) `json:"-" yaml:"-" mapstructure:"-"`
+}
@@ -0,0 +1,487 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError 配置错误
+// Key 指向出错的配置项，如 "pool_config.conn_max_lifetime" 或环境变量名
+type ConfigError struct {
+	// Key 配置项路径
+	Key string
+	// Err 错误原因
+	Err error
+}
+
+// Error 实现error接口
+func (e *ConfigError) Error() string {
+	if e.Key == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+// Unwrap 返回错误原因
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors 配置错误列表
+// validateConfig 一次性返回所有发现的问题
+type ConfigErrors []*ConfigError
+
+// Error 实现error接口
+func (e ConfigErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d config errors: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Unwrap 返回所有错误，支持errors.Is/errors.As
+func (e ConfigErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// newConfigError 创建配置错误
+// 参数:
+//   - key: 配置项路径
+//   - format: 格式字符串
+//   - args: 参数列表
+// 返回值:
+//   - *ConfigError: 配置错误
+func newConfigError(key, format string, args ...interface{}) *ConfigError {
+	return &ConfigError{Key: key, Err: fmt.Errorf(format, args...)}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// LoadConfig 从配置文件加载配置
+// 根据扩展名识别格式 (.yaml/.yml, .json, .toml)，时长字段支持 "1h"、"30s" 等写法；
+// 指定envPrefix时，环境变量中的同名配置覆盖文件中的值，优先级为：环境变量 > 配置文件 > 零值
+// 参数:
+//   - path: 配置文件路径
+//   - envPrefix: 环境变量前缀，可选参数
+// 返回值:
+//   - *Config: 数据库配置
+//   - error: 读取、解析或验证错误，解析错误为*ConfigError，验证错误为ConfigErrors
+func LoadConfig(path string, envPrefix ...string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw, err := unmarshalConfigFile(data, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	config := &Config{}
+	if err := decodeConfigValue("", raw, reflect.ValueOf(config).Elem()); err != nil {
+		return nil, err
+	}
+
+	if len(envPrefix) > 0 && envPrefix[0] != "" {
+		if err := applyConfigEnv(envPrefix[0], reflect.ValueOf(config).Elem()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// LoadConfigFromEnv 从环境变量加载配置
+// 变量名由前缀与mapstructure标签拼接并转为大写，如前缀 "DB" 对应：
+// DB_MASTER、DB_POOL_CONFIG_MAX_OPEN_CONNS、DB_SLAVES_0_DSN；字符串列表以逗号分隔，映射写作 k1=v1,k2=v2
+// 参数:
+//   - prefix: 环境变量前缀
+// 返回值:
+//   - *Config: 数据库配置
+//   - error: 解析错误为*ConfigError，验证错误为ConfigErrors
+func LoadConfigFromEnv(prefix string) (*Config, error) {
+	config := &Config{}
+	if err := applyConfigEnv(prefix, reflect.ValueOf(config).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// unmarshalConfigFile 按格式解析配置文件为通用结构
+// 参数:
+//   - data: 文件内容
+//   - ext: 文件扩展名
+// 返回值:
+//   - map[string]interface{}: 解析结果
+//   - error: 错误信息
+func unmarshalConfigFile(data []byte, ext string) (map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		if _, err := toml.Decode(string(data), &raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format: %q", ext)
+	}
+
+	return raw, nil
+}
+
+// configFieldName 返回结构体字段对应的配置键
+// 参数:
+//   - field: 结构体字段
+// 返回值:
+//   - string: 配置键，"-" 表示不参与加载
+func configFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// joinConfigKey 拼接配置项路径
+func joinConfigKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// decodeConfigValue 将通用结构解码到配置字段
+// 参数:
+//   - key: 当前配置项路径
+//   - in: 输入值
+//   - out: 目标字段
+// 返回值:
+//   - error: 解码错误，为*ConfigError
+func decodeConfigValue(key string, in interface{}, out reflect.Value) error {
+	if in == nil {
+		return nil
+	}
+
+	if out.Type() == durationType {
+		d, err := parseConfigDuration(in)
+		if err != nil {
+			return &ConfigError{Key: key, Err: err}
+		}
+		out.SetInt(int64(d))
+		return nil
+	}
+
+	switch out.Kind() {
+	case reflect.Struct:
+		fields, ok := in.(map[string]interface{})
+		if !ok {
+			return newConfigError(key, "expected a mapping, got %T", in)
+		}
+		return decodeConfigStruct(key, fields, out)
+
+	case reflect.Slice:
+		items, ok := in.([]interface{})
+		if !ok {
+			// 字符串列表允许使用逗号分隔的单个字符串
+			if s, isString := in.(string); isString && out.Type().Elem().Kind() == reflect.String {
+				items = splitConfigList(s)
+			} else {
+				return newConfigError(key, "expected a list, got %T", in)
+			}
+		}
+		slice := reflect.MakeSlice(out.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeConfigValue(fmt.Sprintf("%s[%d]", key, i), item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		out.Set(slice)
+		return nil
+
+	case reflect.String:
+		s, ok := in.(string)
+		if !ok {
+			return newConfigError(key, "expected a string, got %T", in)
+		}
+		out.SetString(s)
+		return nil
+
+	case reflect.Bool:
+		switch v := in.(type) {
+		case bool:
+			out.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return newConfigError(key, "invalid boolean %q", v)
+			}
+			out.SetBool(b)
+		default:
+			return newConfigError(key, "expected a boolean, got %T", in)
+		}
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := parseConfigInt(in)
+		if err != nil {
+			return &ConfigError{Key: key, Err: err}
+		}
+		if out.OverflowInt(n) {
+			return newConfigError(key, "value %d out of range", n)
+		}
+		out.SetInt(n)
+		return nil
+
+	case reflect.Map:
+		if out.Type().Key().Kind() != reflect.String || out.Type().Elem().Kind() != reflect.String {
+			return newConfigError(key, "unsupported field type %s", out.Type())
+		}
+		m := reflect.MakeMap(out.Type())
+		switch v := in.(type) {
+		case map[string]interface{}:
+			for k, item := range v {
+				m.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(fmt.Sprint(item)))
+			}
+		case string:
+			// 环境变量中的映射写作 k1=v1,k2=v2
+			for _, item := range splitConfigList(v) {
+				k, val, ok := strings.Cut(item.(string), "=")
+				if !ok {
+					return newConfigError(key, "invalid map entry %q, expected key=value", item)
+				}
+				m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)), reflect.ValueOf(strings.TrimSpace(val)))
+			}
+		default:
+			return newConfigError(key, "expected a mapping, got %T", in)
+		}
+		out.Set(m)
+		return nil
+
+	case reflect.Interface, reflect.Func, reflect.Ptr:
+		return newConfigError(key, "cannot be set from a config file")
+
+	default:
+		return newConfigError(key, "unsupported field type %s", out.Type())
+	}
+}
+
+// decodeConfigStruct 解码结构体，未知配置键视为错误以便发现拼写问题
+// 参数:
+//   - key: 当前配置项路径
+//   - in: 输入映射
+//   - out: 目标结构体
+// 返回值:
+//   - error: 解码错误
+func decodeConfigStruct(key string, in map[string]interface{}, out reflect.Value) error {
+	known := make(map[string]bool, out.NumField())
+
+	for i := 0; i < out.NumField(); i++ {
+		field := out.Type().Field(i)
+		name := configFieldName(field)
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		known[name] = true
+
+		if value, ok := in[name]; ok {
+			if err := decodeConfigValue(joinConfigKey(key, name), value, out.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 按键名排序，保证错误信息稳定
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !known[k] {
+			return newConfigError(joinConfigKey(key, k), "unknown config key")
+		}
+	}
+
+	return nil
+}
+
+// applyConfigEnv 用环境变量覆盖配置
+// 参数:
+//   - prefix: 环境变量前缀
+//   - out: 目标结构体
+// 返回值:
+//   - error: 解析错误，Key为环境变量名
+func applyConfigEnv(prefix string, out reflect.Value) error {
+	prefix = strings.ToUpper(strings.TrimSuffix(prefix, "_"))
+
+	for i := 0; i < out.NumField(); i++ {
+		field := out.Type().Field(i)
+		name := configFieldName(field)
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+
+		envKey := strings.ToUpper(name)
+		if prefix != "" {
+			envKey = prefix + "_" + envKey
+		}
+		value := out.Field(i)
+
+		switch {
+		case value.Type() == durationType:
+			if s, ok := os.LookupEnv(envKey); ok {
+				if err := decodeConfigValue(envKey, s, value); err != nil {
+					return err
+				}
+			}
+
+		case value.Kind() == reflect.Struct:
+			if err := applyConfigEnv(envKey, value); err != nil {
+				return err
+			}
+
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct:
+			// 结构体列表按下标展开，如 DB_SLAVES_0_DSN
+			for j := 0; hasEnvPrefix(fmt.Sprintf("%s_%d_", envKey, j)); j++ {
+				if j >= value.Len() {
+					value.Set(reflect.Append(value, reflect.Zero(value.Type().Elem())))
+				}
+				if err := applyConfigEnv(fmt.Sprintf("%s_%d", envKey, j), value.Index(j)); err != nil {
+					return err
+				}
+			}
+
+		default:
+			if s, ok := os.LookupEnv(envKey); ok {
+				if err := decodeConfigValue(envKey, s, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasEnvPrefix 判断是否存在以prefix开头的环境变量
+func hasEnvPrefix(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConfigDuration 解析时长配置
+// 字符串按 time.ParseDuration 解析；不带单位的数字容易被误写为秒，除0以外一律拒绝
+// 参数:
+//   - in: 输入值
+// 返回值:
+//   - time.Duration: 时长
+//   - error: 错误信息
+func parseConfigDuration(in interface{}) (time.Duration, error) {
+	s, ok := in.(string)
+	if !ok {
+		n, err := parseConfigInt(in)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %v", err)
+		}
+		s = strconv.FormatInt(n, 10)
+	}
+
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n != 0 {
+			return 0, fmt.Errorf("duration %s is missing a unit, expected a value such as \"%ds\"", s, n)
+		}
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected a value such as \"30s\" or \"1h\"", s)
+	}
+	return d, nil
+}
+
+// parseConfigInt 解析整数配置
+// 参数:
+//   - in: 输入值
+// 返回值:
+//   - int64: 整数
+//   - error: 错误信息
+func parseConfigInt(in interface{}) (int64, error) {
+	switch v := in.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint64:
+		return int64(v), nil
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, fmt.Errorf("expected an integer, got %v", v)
+		}
+		return int64(v), nil
+	case json.Number:
+		return v.Int64()
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer %q", v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %T", in)
+	}
+}
+
+// splitConfigList 拆分逗号分隔的列表
+func splitConfigList(s string) []interface{} {
+	var items []interface{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,271 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// endpoint 单个数据库节点（主库或从库）的连接参数
+type endpoint struct {
+	// dsn 数据源名称
+	dsn string
+	// conn 结构化连接配置
+	conn ConnectionConfig
+	// creds 凭据配置
+	creds CredentialConfig
+	// dbType 数据库类型
+	dbType string
+	// initStatements 新连接建立后执行的初始化语句
+	initStatements []string
+	// tls TLS配置
+	tls TLSConfig
+}
+
+// masterEndpoint 获取主库连接参数
+// 返回值:
+//   - endpoint: 主库连接参数
+func (c *Config) masterEndpoint() endpoint {
+	return endpoint{
+		dsn:            c.Master,
+		conn:           c.Connection,
+		creds:          c.Credentials,
+		dbType:         c.Type,
+		initStatements: c.InitStatements,
+		tls:            c.TLS,
+	}
+}
+
+// slaveEndpoint 获取从库连接参数，未指定类型时沿用主库类型
+// 参数:
+//   - slave: 从库配置
+// 返回值:
+//   - endpoint: 从库连接参数
+func (c *Config) slaveEndpoint(slave SlaveConfig) endpoint {
+	dbType := slave.Type
+	if dbType == "" {
+		dbType = c.Type
+	}
+	return endpoint{
+		dsn:            slave.DSN,
+		conn:           slave.Connection,
+		creds:          slave.Credentials,
+		dbType:         dbType,
+		initStatements: slave.InitStatements,
+		tls:            slave.TLS,
+	}
+}
+
+// connector 在每次建立物理连接时重新生成DSN的连接器
+// 负责展开DSN中的 ${VAR} 引用、向凭据提供者查询最新密码、应用TLS配置，并在连接建立后执行初始化语句
+type connector struct {
+	// dbType 数据库类型
+	dbType string
+	// dsn DSN模板，可能包含 ${VAR} 引用
+	dsn string
+	// credentials 凭据提供者，可为nil
+	credentials CredentialProvider
+	// initStatements 连接建立后执行的初始化语句
+	initStatements []string
+	// tls TLS配置，为空时使用DSN中的设置
+	tls TLSConfig
+	// driver 底层驱动
+	driver driver.Driver
+}
+
+// newConnector 创建连接器
+// 参数:
+//   - dbType: 数据库类型
+//   - dsn: DSN模板
+//   - credentials: 凭据提供者，可为nil
+//   - initStatements: 初始化语句
+//   - tlsConfig: TLS配置
+// 返回值:
+//   - *connector: 连接器
+//   - error: 错误信息
+func newConnector(dbType, dsn string, credentials CredentialProvider, initStatements []string, tlsConfig TLSConfig) (*connector, error) {
+	d := lookupDialect(dbType)
+	if d == nil || d.driverName == "" {
+		return nil, fmt.Errorf("%s does not support credentials, init statements, tls config or ${VAR} references", dbType)
+	}
+
+	// sql.Open只查找已注册的驱动，不会建立连接
+	db, err := sql.Open(d.driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	db.Close()
+
+	return &connector{
+		dbType:         dbType,
+		dsn:            dsn,
+		credentials:    credentials,
+		initStatements: initStatements,
+		tls:            tlsConfig,
+		driver:         drv,
+	}, nil
+}
+
+// Connect 建立新的物理连接
+// 初始化语句执行失败时关闭连接并返回错误，该错误即为本次获取连接的错误
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - driver.Conn: 数据库连接
+//   - error: 错误信息
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.currentDSN(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inner, err := c.openConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := inner.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.runInitStatements(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// openConnector 为本次连接创建驱动连接器
+// 参数:
+//   - dsn: 数据源名称
+// 返回值:
+//   - driver.Connector: 驱动连接器
+//   - error: 错误信息
+func (c *connector) openConnector(dsn string) (driver.Connector, error) {
+	if !c.tls.IsZero() {
+		return openTLSConnector(c.dbType, dsn, c.tls)
+	}
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		return dc.OpenConnector(dsn)
+	}
+	return dsnConnector{driver: c.driver, dsn: dsn}, nil
+}
+
+// Driver 返回底层驱动
+// 返回值:
+//   - driver.Driver: 底层驱动
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// currentDSN 生成本次连接使用的DSN
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - string: DSN
+//   - error: 错误信息
+func (c *connector) currentDSN(ctx context.Context) (string, error) {
+	dsn := c.dsn
+	if hasEnvReference(dsn) {
+		dsn = expandEnv(dsn)
+	}
+	if c.credentials == nil {
+		return dsn, nil
+	}
+
+	password, err := c.credentials.Password(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get database password: %w", err)
+	}
+	return withPassword(c.dbType, dsn, password)
+}
+
+// runInitStatements 在新连接上依次执行初始化语句
+// 参数:
+//   - ctx: 上下文
+//   - conn: 新建立的连接
+// 返回值:
+//   - error: 错误信息
+func (c *connector) runInitStatements(ctx context.Context, conn driver.Conn) error {
+	for _, statement := range c.initStatements {
+		if err := execStatement(ctx, conn, statement); err != nil {
+			return fmt.Errorf("failed to run init statement %q: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// execStatement 在驱动连接上执行不带参数的语句
+// 参数:
+//   - ctx: 上下文
+//   - conn: 驱动连接
+//   - statement: SQL语句
+// 返回值:
+//   - error: 错误信息
+func execStatement(ctx context.Context, conn driver.Conn, statement string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, statement, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	// 驱动未实现ExecerContext时回退到预处理语句
+	stmt, err := conn.Prepare(statement)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(nil)
+	return err
+}
+
+// dsnConnector 适配未实现driver.DriverContext的驱动
+type dsnConnector struct {
+	// driver 底层驱动
+	driver driver.Driver
+	// dsn 数据源名称
+	dsn string
+}
+
+// Connect 建立新的物理连接
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - driver.Conn: 数据库连接
+//   - error: 错误信息
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver 返回底层驱动
+// 返回值:
+//   - driver.Driver: 底层驱动
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// validateInitStatements 校验初始化语句配置
+// 参数:
+//   - statements: 初始化语句
+//   - dbType: 数据库类型
+// 返回值:
+//   - error: 校验错误
+func validateInitStatements(statements []string, dbType string) error {
+	if len(statements) == 0 {
+		return nil
+	}
+	for i, statement := range statements {
+		if strings.TrimSpace(statement) == "" {
+			return fmt.Errorf("init statement %d cannot be empty", i)
+		}
+	}
+	if d := lookupDialect(dbType); d != nil && d.driverName == "" {
+		return fmt.Errorf("%s does not support init statements", dbType)
+	}
+	return nil
+}
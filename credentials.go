@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// CredentialProvider 凭据提供者接口
+// 每次建立新的物理连接时都会重新调用，返回新密码后新连接即使用新密码，已建立的连接不受影响
+type CredentialProvider interface {
+	// Password 返回当前的数据库密码
+	Password(ctx context.Context) (string, error)
+}
+
+// CredentialProviderFunc 函数形式的凭据提供者
+type CredentialProviderFunc func(ctx context.Context) (string, error)
+
+// Password 返回当前的数据库密码
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - string: 密码
+//   - error: 错误信息
+func (f CredentialProviderFunc) Password(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// CredentialConfig 凭据配置结构体
+// 设置后密码不再从DSN读取，而是在建立连接时向提供者查询，便于密码轮换
+type CredentialConfig struct {
+	// 密码文件路径，每次建立连接时重新读取，忽略末尾换行
+	PasswordFile string `json:"password_file" yaml:"password_file" mapstructure:"password_file"`
+	// 保存密码的环境变量名，每次建立连接时重新读取
+	PasswordEnv string `json:"password_env" yaml:"password_env" mapstructure:"password_env"`
+	// 自定义凭据提供者，优先于PasswordFile和PasswordEnv
+	Provider CredentialProvider `json:"-" yaml:"-" mapstructure:"-"`
+}
+
+// NewFileCredentialProvider 创建从文件读取密码的凭据提供者
+// 适用于Kubernetes Secret、Vault Agent等以文件形式挂载并定期更新的密钥
+// 参数:
+//   - path: 密码文件路径
+// 返回值:
+//   - CredentialProvider: 凭据提供者
+func NewFileCredentialProvider(path string) CredentialProvider {
+	return CredentialProviderFunc(func(ctx context.Context) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	})
+}
+
+// NewEnvCredentialProvider 创建从环境变量读取密码的凭据提供者
+// 参数:
+//   - name: 环境变量名
+// 返回值:
+//   - CredentialProvider: 凭据提供者
+func NewEnvCredentialProvider(name string) CredentialProvider {
+	return CredentialProviderFunc(func(ctx context.Context) (string, error) {
+		password, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("password environment variable %s is not set", name)
+		}
+		return password, nil
+	})
+}
+
+// NewExpandCredentialProvider 创建展开 ${VAR} 引用的凭据提供者
+// 每次调用时按当前环境变量展开模板，如 "${DB_PASSWORD}"
+// 参数:
+//   - template: 包含 ${VAR} 引用的密码模板
+// 返回值:
+//   - CredentialProvider: 凭据提供者
+func NewExpandCredentialProvider(template string) CredentialProvider {
+	return CredentialProviderFunc(func(ctx context.Context) (string, error) {
+		return expandEnv(template), nil
+	})
+}
+
+// hasEnvReference 判断字符串是否包含 ${VAR} 引用
+func hasEnvReference(s string) bool {
+	return strings.Contains(s, "${")
+}
+
+// expandEnv 展开字符串中的 ${VAR} 引用
+// 只处理 ${VAR} 形式，其他 $ 字符保持原样，避免密码中的 $word 被当作变量展开
+// 参数:
+//   - s: 包含 ${VAR} 引用的字符串
+// 返回值:
+//   - string: 展开后的字符串
+func expandEnv(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start+2:], '}')
+		if end < 0 {
+			break
+		}
+		end += start + 2
+
+		b.WriteString(s[:start])
+		if name := s[start+2 : end]; name != "" {
+			b.WriteString(os.Getenv(name))
+		} else {
+			b.WriteString("${}")
+		}
+		s = s[end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// credentialProvider 确定连接使用的凭据提供者
+// 优先级: Provider > PasswordFile > PasswordEnv > 结构化连接中包含 ${VAR} 的密码
+// 参数:
+//   - creds: 凭据配置
+//   - conn: 结构化连接配置
+// 返回值:
+//   - CredentialProvider: 凭据提供者，无需动态密码时为nil
+func credentialProvider(creds CredentialConfig, conn ConnectionConfig) CredentialProvider {
+	switch {
+	case creds.Provider != nil:
+		return creds.Provider
+	case creds.PasswordFile != "":
+		return NewFileCredentialProvider(creds.PasswordFile)
+	case creds.PasswordEnv != "":
+		return NewEnvCredentialProvider(creds.PasswordEnv)
+	case hasEnvReference(conn.Password):
+		return NewExpandCredentialProvider(conn.Password)
+	default:
+		return nil
+	}
+}
+
+// validateCredentials 校验凭据配置
+// 参数:
+//   - creds: 凭据配置
+//   - dbType: 数据库类型
+// 返回值:
+//   - error: 校验错误
+func validateCredentials(creds CredentialConfig, dbType string) error {
+	if creds.Provider == nil && creds.PasswordFile == "" && creds.PasswordEnv == "" {
+		return nil
+	}
+	if creds.PasswordFile != "" && creds.PasswordEnv != "" {
+		return fmt.Errorf("password_file and password_env cannot both be set")
+	}
+	switch dialectFamily(dbType) {
+	case "mysql", "postgres":
+	case "sqlite":
+		return fmt.Errorf("sqlite does not support credentials")
+	default:
+		return fmt.Errorf("%s does not support credentials", dbType)
+	}
+	return nil
+}
+
+// withPassword 将密码写入DSN，覆盖DSN中原有的密码
+// 参数:
+//   - dbType: 数据库类型
+//   - dsn: 数据源名称
+//   - password: 密码
+// 返回值:
+//   - string: 新的DSN
+//   - error: 错误信息
+func withPassword(dbType, dsn, password string) (string, error) {
+	switch dialectFamily(dbType) {
+	case "mysql":
+		cfg, err := mysqldriver.ParseDSN(dsn)
+		if err != nil {
+			return "", err
+		}
+		cfg.Passwd = password
+		return cfg.FormatDSN(), nil
+	case "postgres":
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			u, err := url.Parse(dsn)
+			if err != nil {
+				return "", fmt.Errorf("invalid postgres url")
+			}
+			username := ""
+			if u.User != nil {
+				username = u.User.Username()
+			}
+			u.User = url.UserPassword(username, password)
+			return u.String(), nil
+		}
+		// 关键字/值格式中后出现的同名参数生效
+		escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(password)
+		return strings.TrimSpace(dsn) + " password='" + escaped + "'", nil
+	default:
+		return "", fmt.Errorf("%s does not support credentials", dbType)
+	}
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// DialectorFactory 根据DSN创建GORM方言
+type DialectorFactory func(dsn string) gorm.Dialector
+
+// dialect 已注册的数据库方言
+type dialect struct {
+	// name 标准名称
+	name string
+	// factory 方言构造函数
+	factory DialectorFactory
+	// family 内置方言族 (mysql, postgres, sqlite)，决定DSN的解析、生成和凭据注入方式；自定义方言为空
+	family string
+	// driverName database/sql中注册的驱动名，为空时不支持凭据提供者和DSN中的 ${VAR} 引用
+	driverName string
+	// setConnPool 让方言使用指定的连接池，方言类型不匹配时返回false
+	setConnPool func(dialector gorm.Dialector, pool gorm.ConnPool) bool
+}
+
+var (
+	// dialectsMu 保护方言注册表
+	dialectsMu sync.RWMutex
+	// dialects 方言注册表，键为小写的名称或别名
+	dialects = make(map[string]*dialect)
+)
+
+func init() {
+	registerDialect(&dialect{
+		name:        "mysql",
+		factory:     mysql.Open,
+		family:      "mysql",
+		driverName:  "mysql",
+		setConnPool: setMySQLConnPool,
+	})
+	registerDialect(&dialect{
+		name:        "postgres",
+		factory:     postgres.Open,
+		family:      "postgres",
+		driverName:  "pgx",
+		setConnPool: setPostgresConnPool,
+	}, "postgresql")
+}
+
+// RegisterDialect 注册数据库方言
+// 注册后即可在Config.Type和SlaveConfig.Type中使用名称或别名（不区分大小写），用于接入SQL Server、ClickHouse、TiDB等数据库。
+// 自定义方言的DSN原样交给factory，不支持结构化连接配置和凭据提供者；
+// 与内置方言同名时替换其实现，并保留内置方言的DSN解析方式
+// 参数:
+//   - name: 方言名称
+//   - factory: 方言构造函数
+//   - aliases: 别名
+func RegisterDialect(name string, factory DialectorFactory, aliases ...string) {
+	if name == "" {
+		panic("database: RegisterDialect name is empty")
+	}
+	if factory == nil {
+		panic("database: RegisterDialect factory is nil for " + name)
+	}
+
+	d := &dialect{name: strings.ToLower(name), factory: factory}
+	if existing := lookupDialect(name); existing != nil && existing.name == d.name {
+		d.family = existing.family
+		d.driverName = existing.driverName
+		d.setConnPool = existing.setConnPool
+	}
+	registerDialect(d, aliases...)
+}
+
+// registerDialect 将方言及其别名写入注册表
+// 参数:
+//   - d: 方言
+//   - aliases: 别名
+func registerDialect(d *dialect, aliases ...string) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+
+	dialects[d.name] = d
+	for _, alias := range aliases {
+		dialects[strings.ToLower(alias)] = d
+	}
+}
+
+// unregisterDialect 移除方言及指向它的所有别名，用于测试结束后清理注册表
+// 参数:
+//   - name: 方言名称或别名
+func unregisterDialect(name string) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+
+	d := dialects[strings.ToLower(name)]
+	if d == nil {
+		return
+	}
+	for key, registered := range dialects {
+		if registered == d {
+			delete(dialects, key)
+		}
+	}
+}
+
+// lookupDialect 查找已注册的方言
+// 参数:
+//   - dbType: 数据库类型或别名
+// 返回值:
+//   - *dialect: 方言，未注册时为nil
+func lookupDialect(dbType string) *dialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	return dialects[strings.ToLower(dbType)]
+}
+
+// normalizeDBType 将数据库类型及其别名转换为标准名称
+// 参数:
+//   - dbType: 数据库类型
+// 返回值:
+//   - string: 标准名称，未注册的类型返回空字符串
+func normalizeDBType(dbType string) string {
+	if d := lookupDialect(dbType); d != nil {
+		return d.name
+	}
+	return ""
+}
+
+// dialectFamily 获取数据库类型所属的内置方言族
+// 参数:
+//   - dbType: 数据库类型
+// 返回值:
+//   - string: 方言族 (mysql, postgres, sqlite)，自定义或未注册的类型返回空字符串
+func dialectFamily(dbType string) string {
+	if d := lookupDialect(dbType); d != nil {
+		return d.family
+	}
+	return ""
+}
+
+// withConnPool 让方言使用指定的连接池，而不是自行打开连接
+// 参数:
+//   - dbType: 数据库类型
+//   - dialector: GORM方言
+//   - pool: 连接池
+// 返回值:
+//   - gorm.Dialector: GORM方言
+//   - error: 方言不支持指定连接池时返回错误
+func withConnPool(dbType string, dialector gorm.Dialector, pool gorm.ConnPool) (gorm.Dialector, error) {
+	d := lookupDialect(dbType)
+	if d == nil || d.setConnPool == nil || !d.setConnPool(dialector, pool) {
+		return nil, fmt.Errorf("dialect %s does not support custom connection pools", dialector.Name())
+	}
+	return dialector, nil
+}
+
+// setMySQLConnPool 设置MySQL方言的连接池
+func setMySQLConnPool(dialector gorm.Dialector, pool gorm.ConnPool) bool {
+	d, ok := dialector.(*mysql.Dialector)
+	if ok {
+		d.Config.Conn = pool
+	}
+	return ok
+}
+
+// setPostgresConnPool 设置PostgreSQL方言的连接池
+func setPostgresConnPool(dialector gorm.Dialector, pool gorm.ConnPool) bool {
+	d, ok := dialector.(*postgres.Dialector)
+	if ok {
+		d.Config.Conn = pool
+	}
+	return ok
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+)
+
+// txState 事务状态
+type txState int
+
+const (
+	// txActive 事务进行中
+	txActive txState = iota
+	// txCommitted 事务已提交
+	txCommitted
+	// txRolledBack 事务或保存点已回滚
+	txRolledBack
+	// txReleased 保存点已释放，回调由外层事务负责
+	txReleased
+	// txAbandoned 提交结果未知，回调均不执行
+	txAbandoned
+)
+
+// AfterCommit 注册在事务提交后执行的回调
+// 回调在最外层事务真正提交成功后按注册顺序执行，事务回滚或提交失败时不执行，适合发布领域事件、清理缓存等；
+// 在随后回滚的保存点中注册的回调会被丢弃。ctx中没有活动事务时立即执行
+// 参数:
+//   - ctx: 上下文，事务中使用tx.Statement.Context
+//   - fn: 回调函数
+func AfterCommit(ctx context.Context, fn func()) {
+	if fn == nil {
+		return
+	}
+	if value, ok := ctx.Value(txKey{}).(*txValue); ok {
+		value.addHook(true, fn)
+		return
+	}
+	fn()
+}
+
+// AfterRollback 注册在事务回滚后执行的回调
+// 回调在最外层事务回滚（包括fn返回错误、panic和提交失败）后按注册顺序执行，提交结果未知时不执行；
+// 在保存点中注册且该保存点回滚时，回调在最外层事务结束（无论提交、回滚或结果未知）后执行。ctx中没有活动事务时不执行
+// 参数:
+//   - ctx: 上下文，事务中使用tx.Statement.Context
+//   - fn: 回调函数
+func AfterRollback(ctx context.Context, fn func()) {
+	if fn == nil {
+		return
+	}
+	if value, ok := ctx.Value(txKey{}).(*txValue); ok {
+		value.addHook(false, fn)
+	}
+}
+
+// addHook 注册事务结束后的回调
+// 事务已结束时按结果立即执行或丢弃；保存点已释放时转交给外层事务
+// 参数:
+//   - onCommit: 是否为提交后执行的回调
+//   - fn: 回调函数
+func (v *txValue) addHook(onCommit bool, fn func()) {
+	v.mu.Lock()
+	state := v.state
+	if state == txActive {
+		if onCommit {
+			v.afterCommit = append(v.afterCommit, fn)
+		} else {
+			v.afterRollback = append(v.afterRollback, fn)
+		}
+	}
+	v.mu.Unlock()
+
+	switch state {
+	case txReleased:
+		v.parent.addHook(onCommit, fn)
+	case txRolledBack:
+		if onCommit {
+			return
+		}
+		if v.parent != nil {
+			v.root().addSavepointHook(fn)
+			return
+		}
+		v.runHook(fn)
+	case txCommitted:
+		if onCommit {
+			v.runHook(fn)
+		}
+	}
+}
+
+// addSavepointHook 在最外层事务上登记已回滚保存点的回滚回调
+// 最外层事务已结束时立即执行
+// 参数:
+//   - fn: 回调函数
+func (v *txValue) addSavepointHook(fn func()) {
+	v.mu.Lock()
+	if v.state == txActive {
+		v.afterSavepoint = append(v.afterSavepoint, fn)
+		v.mu.Unlock()
+		return
+	}
+	v.mu.Unlock()
+	v.runHook(fn)
+}
+
+// release 释放保存点，将回调转交给外层事务
+func (v *txValue) release() {
+	v.mu.Lock()
+	v.state = txReleased
+	afterCommit, afterRollback := v.afterCommit, v.afterRollback
+	v.afterCommit, v.afterRollback = nil, nil
+	v.mu.Unlock()
+
+	for _, fn := range afterCommit {
+		v.parent.addHook(true, fn)
+	}
+	for _, fn := range afterRollback {
+		v.parent.addHook(false, fn)
+	}
+}
+
+// finish 结束事务并执行对应的回调
+// 保存点回滚时其回滚回调转交给最外层事务；最外层事务先执行已回滚保存点的回调，再执行自身的回调
+// 参数:
+//   - committed: 事务是否已提交
+func (v *txValue) finish(committed bool) {
+	v.mu.Lock()
+	hooks := v.afterRollback
+	v.state = txRolledBack
+	if committed {
+		hooks = v.afterCommit
+		v.state = txCommitted
+	}
+	savepointHooks := v.afterSavepoint
+	v.afterCommit, v.afterRollback, v.afterSavepoint = nil, nil, nil
+	v.mu.Unlock()
+
+	if !committed && v.parent != nil {
+		root := v.root()
+		for _, fn := range hooks {
+			root.addSavepointHook(fn)
+		}
+		return
+	}
+
+	for _, fn := range savepointHooks {
+		v.runHook(fn)
+	}
+	for _, fn := range hooks {
+		v.runHook(fn)
+	}
+}
+
+// abandon 提交结果未知时结束事务并丢弃自身的回调
+// 已回滚保存点中的操作确定已撤销，其回滚回调仍会执行
+func (v *txValue) abandon() {
+	v.mu.Lock()
+	v.state = txAbandoned
+	savepointHooks := v.afterSavepoint
+	v.afterCommit, v.afterRollback, v.afterSavepoint = nil, nil, nil
+	v.mu.Unlock()
+
+	for _, fn := range savepointHooks {
+		v.runHook(fn)
+	}
+}
+
+// runHook 执行回调，回调panic时记录错误日志，不影响事务结果和其他回调
+// 参数:
+//   - fn: 回调函数
+func (v *txValue) runHook(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			v.manager.logger.Error(v.tx.Statement.Context, "transaction callback panicked: %v", r)
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAlreadyCommitted 幂等键对应的事务已经提交过，本次没有执行fn
+var ErrAlreadyCommitted = errors.New("transaction already committed")
+
+// TransactionKey 事务幂等键表模型
+// 幂等键与业务修改写入同一事务，提交结果未知时通过查询幂等键判断事务是否已生效
+type TransactionKey struct {
+	// Key 幂等键，由调用方生成，如请求ID
+	Key string `gorm:"column:idempotency_key;primaryKey;size:191"`
+	// CreatedAt 写入时间，用于清理过期的幂等键
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// TableName 返回表名
+// 返回值:
+//   - string: 表名
+func (TransactionKey) TableName() string {
+	return "transaction_keys"
+}
+
+// MigrateTransactionKeys 创建或更新幂等键表
+// 参数:
+//   - db: 数据库实例
+// 返回值:
+//   - error: 错误信息
+func MigrateTransactionKeys(db *gorm.DB) error {
+	return db.AutoMigrate(&TransactionKey{})
+}
+
+// TransactionKeyExists 判断幂等键对应的事务是否已提交
+// 参数:
+//   - db: 数据库实例，应使用主库以免读到复制延迟前的数据
+//   - key: 幂等键
+// 返回值:
+//   - bool: 是否已提交
+//   - error: 错误信息
+func TransactionKeyExists(db *gorm.DB, key string) (bool, error) {
+	var count int64
+	if err := db.Model(&TransactionKey{}).Where("idempotency_key = ?", key).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to query transaction key: %w", err)
+	}
+	return count > 0, nil
+}
+
+// PurgeTransactionKeys 删除指定时间之前写入的幂等键
+// 参数:
+//   - db: 数据库实例
+//   - before: 截止时间
+// 返回值:
+//   - int64: 删除的数量
+//   - error: 错误信息
+func PurgeTransactionKeys(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("created_at < ?", before).Delete(&TransactionKey{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge transaction keys: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// recordTransactionKey 在事务中写入幂等键
+// 参数:
+//   - tx: 事务实例
+//   - key: 幂等键
+// 返回值:
+//   - error: 幂等键已存在时返回ErrAlreadyCommitted
+func recordTransactionKey(tx *gorm.DB, key string) error {
+	exists, err := TransactionKeyExists(tx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: idempotency key %s", ErrAlreadyCommitted, key)
+	}
+	// 并发使用同一幂等键时由主键约束保证只有一个事务提交
+	if err := tx.Create(&TransactionKey{Key: key}).Error; err != nil {
+		return fmt.Errorf("failed to record transaction key: %w", err)
+	}
+	return nil
+}
+
+// resolveCommit 提交结果未知时通过幂等键判断事务是否已提交
+// 参数:
+//   - ctx: 调用方的上下文
+//   - key: 幂等键
+// 返回值:
+//   - commitOutcome: 判断后的分类，查询失败时仍为commitUnknown
+func (m *DBManager) resolveCommit(ctx context.Context, key string) commitOutcome {
+	exists, err := TransactionKeyExists(m.GetMasterDB().WithContext(ctx), key)
+	if err != nil {
+		m.logger.Warn(ctx, "failed to resolve transaction commit outcome: %v", err)
+		return commitUnknown
+	}
+	if exists {
+		return commitCommitted
+	}
+	return commitFailed
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 内置的上下文日志字段名
+const (
+	// LogFieldRequestID 请求ID字段名
+	LogFieldRequestID = "request_id"
+	// LogFieldTraceID 链路追踪ID字段名
+	LogFieldTraceID = "trace_id"
+	// LogFieldUserID 用户ID字段名
+	LogFieldUserID = "user_id"
+	// LogFieldTxID 事务ID字段名，事务内执行的SQL日志自动携带
+	LogFieldTxID = "tx_id"
+)
+
+// logFieldsKey 自定义日志字段的上下文键
+type logFieldsKey struct{}
+
+// requestIDKey 请求ID的上下文键
+type requestIDKey struct{}
+
+// traceIDKey 链路追踪ID的上下文键
+type traceIDKey struct{}
+
+// userIDKey 用户ID的上下文键
+type userIDKey struct{}
+
+// WithLogFields 向上下文追加日志字段
+// 字段会出现在该上下文产生的每条SQL日志和慢查询记录中
+// 参数:
+//   - ctx: 上下文
+//   - keysAndValues: 交替出现的键值对，如 "order_id", 42
+// 返回值:
+//   - context.Context: 携带日志字段的新上下文
+func WithLogFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	if len(keysAndValues) == 0 {
+		return ctx
+	}
+	if len(keysAndValues)%2 != 0 {
+		keysAndValues = append(keysAndValues, "(MISSING)")
+	}
+
+	existing, _ := ctx.Value(logFieldsKey{}).([]interface{})
+	fields := make([]interface{}, 0, len(existing)+len(keysAndValues))
+	fields = append(fields, existing...)
+	fields = append(fields, keysAndValues...)
+	return context.WithValue(ctx, logFieldsKey{}, fields)
+}
+
+// WithRequestID 在上下文中设置请求ID
+// 参数:
+//   - ctx: 上下文
+//   - requestID: 请求ID
+// 返回值:
+//   - context.Context: 新上下文
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// WithTraceID 在上下文中设置链路追踪ID
+// 参数:
+//   - ctx: 上下文
+//   - traceID: 链路追踪ID
+// 返回值:
+//   - context.Context: 新上下文
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// WithUserID 在上下文中设置用户ID
+// 参数:
+//   - ctx: 上下文
+//   - userID: 用户ID
+// 返回值:
+//   - context.Context: 新上下文
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// LogFieldsFromContext 提取上下文中的日志字段
+// 依次提取请求ID、链路追踪ID、用户ID和WithLogFields追加的字段；
+// 内置字段未通过WithRequestID等设置时，回退读取以 "request_id"、"trace_id"、"user_id" 字符串为键的值，
+// 以兼容直接使用字符串键写入上下文的Web框架。自定义Logger实现可调用此函数输出相同的字段。
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - []interface{}: 交替出现的键值对
+func LogFieldsFromContext(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	var fields []interface{}
+	builtins := []struct {
+		name string
+		key  interface{}
+	}{
+		{LogFieldRequestID, requestIDKey{}},
+		{LogFieldTraceID, traceIDKey{}},
+		{LogFieldUserID, userIDKey{}},
+	}
+	for _, builtin := range builtins {
+		value := ctx.Value(builtin.key)
+		if value == nil {
+			value = ctx.Value(builtin.name)
+		}
+		if value != nil && value != "" {
+			fields = append(fields, builtin.name, value)
+		}
+	}
+
+	if custom, ok := ctx.Value(logFieldsKey{}).([]interface{}); ok {
+		fields = append(fields, custom...)
+	}
+
+	return fields
+}
+
+// formatLogFields 将日志字段格式化为 " key=value" 形式
+// 参数:
+//   - fields: 交替出现的键值对
+// 返回值:
+//   - string: 格式化后的字段，无字段时为空字符串
+func formatLogFields(fields []interface{}) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := 0; i+1 < len(fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", fields[i], fields[i+1])
+	}
+	return b.String()
+}
@@ -2,9 +2,20 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 // DefaultLogger 默认日志记录器实现
@@ -16,8 +27,13 @@ type DefaultLogger struct {
 	logger *log.Logger
 	// logLevel 当前日志级别
 	logLevel LogLevel
+	// slowThreshold 慢查询警告阈值，0表示使用默认的200ms
+	slowThreshold time.Duration
 }
 
+// defaultSlowThreshold 未配置慢查询阈值时的默认值
+const defaultSlowThreshold = 200 * time.Millisecond
+
 // LogMode 设置日志模式
 // 参数:
 //   - level: 日志级别
@@ -36,7 +52,7 @@ func (l *DefaultLogger) LogMode(level LogLevel) Logger {
 //   - data: 附加数据
 func (l *DefaultLogger) Info(ctx context.Context, msg string, data ...interface{}) {
 	if l.logLevel >= Info {
-		l.printf("[INFO] "+msg, data...)
+		l.printf(ctx, "[INFO] "+msg, data...)
 	}
 }
 
@@ -47,7 +63,7 @@ func (l *DefaultLogger) Info(ctx context.Context, msg string, data ...interface{
 //   - data: 附加数据
 func (l *DefaultLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
 	if l.logLevel >= Warn {
-		l.printf("[WARN] "+msg, data...)
+		l.printf(ctx, "[WARN] "+msg, data...)
 	}
 }
 
@@ -58,7 +74,7 @@ func (l *DefaultLogger) Warn(ctx context.Context, msg string, data ...interface{
 //   - data: 附加数据
 func (l *DefaultLogger) Error(ctx context.Context, msg string, data ...interface{}) {
 	if l.logLevel >= Error {
-		l.printf("[ERROR] "+msg, data...)
+		l.printf(ctx, "[ERROR] "+msg, data...)
 	}
 }
 
@@ -78,39 +94,113 @@ func (l *DefaultLogger) Trace(ctx context.Context, begin time.Time, fc func() (s
 
 	switch {
 	case err != nil && l.logLevel >= Error:
-		l.printf("[ERROR] SQL执行失败 [%v] [rows:%d] %s | %v", elapsed, rows, sql, err)
+		l.printf(ctx, "[ERROR] SQL执行失败 [%v] [rows:%d] %s | %v", elapsed, rows, sql, err)
 	case elapsed > l.getSlowThreshold() && l.logLevel >= Warn:
-		l.printf("[WARN] 慢查询检测 [%v] [rows:%d] %s", elapsed, rows, sql)
+		l.printf(ctx, "[WARN] 慢查询检测 [%v] [rows:%d] %s", elapsed, rows, sql)
 	case l.logLevel == Info:
-		l.printf("[INFO] SQL执行 [%v] [rows:%d] %s", elapsed, rows, sql)
+		l.printf(ctx, "[INFO] SQL执行 [%v] [rows:%d] %s", elapsed, rows, sql)
 	}
 }
 
 // printf 格式化输出日志
+// 上下文中的日志字段追加在行尾
 // 参数:
+//   - ctx: 上下文
 //   - format: 格式字符串
 //   - args: 参数列表
-func (l *DefaultLogger) printf(format string, args ...interface{}) {
-	l.logger.Printf(format, args...)
+func (l *DefaultLogger) printf(ctx context.Context, format string, args ...interface{}) {
+	l.logger.Print(fmt.Sprintf(format, args...) + formatLogFields(LogFieldsFromContext(ctx)))
 }
 
 // getSlowThreshold 获取慢查询阈值
 // 返回值:
 //   - time.Duration: 慢查询阈值
 func (l *DefaultLogger) getSlowThreshold() time.Duration {
-	// 默认200ms
-	return 200 * time.Millisecond
+	if l.slowThreshold > 0 {
+		return l.slowThreshold
+	}
+	return defaultSlowThreshold
+}
+
+// withSlowThreshold 返回使用指定慢查询阈值的副本
+// 参数:
+//   - threshold: 慢查询阈值
+// 返回值:
+//   - *DefaultLogger: 日志记录器副本
+func (l *DefaultLogger) withSlowThreshold(threshold time.Duration) *DefaultLogger {
+	newLogger := *l
+	newLogger.slowThreshold = threshold
+	return &newLogger
 }
 
 // SlowQueryLogger 慢查询日志记录器
-// 专门用于记录和分析慢查询
+// 专门用于记录和分析慢查询，每条慢查询以一行JSON写入独立的输出
 type SlowQueryLogger struct {
 	// config 慢查询配置
 	config SlowQueryConfig
-	// baseLogger 基础日志记录器
+	// baseLogger 基础日志记录器，用于报告写入失败
 	baseLogger Logger
-	// logger 标准库日志记录器
-	logger *log.Logger
+	// dbType 数据库类型，用于计算SQL指纹
+	dbType string
+	// mu 互斥锁，保证每条记录完整写入
+	mu sync.Mutex
+	// writer 慢查询输出
+	writer io.Writer
+	// closer 由日志记录器打开的文件，关闭管理器时释放
+	closer io.Closer
+}
+
+// SlowQueryRecord 慢查询记录
+// 字段与常见慢日志分析工具的JSON格式保持一致
+type SlowQueryRecord struct {
+	// Timestamp 查询开始时间
+	Timestamp time.Time `json:"timestamp"`
+	// Duration 执行耗时（秒）
+	Duration float64 `json:"duration"`
+	// Rows 影响行数
+	Rows int64 `json:"rows"`
+	// Fingerprint SQL指纹
+	Fingerprint string `json:"fingerprint"`
+	// SQL 完整SQL，未启用LogParams时省略
+	SQL string `json:"sql,omitempty"`
+	// Node 执行节点 (master, slave)
+	Node string `json:"node"`
+	// Error 错误信息
+	Error string `json:"error,omitempty"`
+	// Fields 上下文日志字段
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// newSlowQueryLogger 创建慢查询日志记录器
+// 输出优先使用config.Writer，其次为config.Output指定的文件，均未配置时写入标准输出
+// 参数:
+//   - config: 慢查询配置
+//   - baseLogger: 基础日志记录器
+//   - dbType: 数据库类型
+// 返回值:
+//   - *SlowQueryLogger: 慢查询日志记录器
+//   - error: 打开日志文件失败时返回错误
+func newSlowQueryLogger(config SlowQueryConfig, baseLogger Logger, dbType string) (*SlowQueryLogger, error) {
+	s := &SlowQueryLogger{
+		config:     config,
+		baseLogger: baseLogger,
+		dbType:     dbType,
+		writer:     os.Stdout,
+	}
+
+	switch {
+	case config.Writer != nil:
+		s.writer = config.Writer
+	case config.Output != "" && config.Enabled:
+		file, err := newRotatingFile(config.Output, config.MaxSize, config.MaxAge, config.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		s.writer = file
+		s.closer = file
+	}
+
+	return s, nil
 }
 
 // LogMode 设置日志模式
@@ -169,26 +259,132 @@ func (s *SlowQueryLogger) Trace(ctx context.Context, begin time.Time, fc func()
 	elapsed := time.Since(begin)
 
 	// 只记录超过阈值的查询
-	if elapsed >= s.config.Threshold {
-		sql, rows := fc()
-		
-		// 构建慢查询日志信息
-		logInfo := fmt.Sprintf("慢查询检测 - 执行时间: %v, 影响行数: %d", elapsed, rows)
-		
-		if s.config.LogParams {
-			logInfo += fmt.Sprintf(", SQL: %s", sql)
+	if elapsed < s.config.Threshold {
+		return
+	}
+
+	sql, rows := fc()
+	node, sql := splitResolverMode(ctx, sql)
+
+	record := SlowQueryRecord{
+		Timestamp:   begin,
+		Duration:    elapsed.Seconds(),
+		Rows:        rows,
+		Fingerprint: fingerprintSQL(sql, s.dbType),
+		Node:        node,
+	}
+	if s.config.LogParams {
+		record.SQL = sql
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if fields := LogFieldsFromContext(ctx); len(fields) > 0 {
+		record.Fields = make(map[string]interface{}, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			record.Fields[fmt.Sprint(fields[i])] = fields[i+1]
 		}
-		
-		if err != nil {
-			logInfo += fmt.Sprintf(", 错误: %v", err)
+	}
+
+	if werr := s.write(record); werr != nil && s.baseLogger != nil {
+		s.baseLogger.Error(ctx, "写入慢查询日志失败: %v", werr)
+	}
+}
+
+// Close 关闭慢查询日志文件
+// 返回值:
+//   - error: 错误信息
+func (s *SlowQueryLogger) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// write 以JSON行格式写入慢查询记录
+// 参数:
+//   - record: 慢查询记录
+// 返回值:
+//   - error: 错误信息
+func (s *SlowQueryLogger) write(record SlowQueryRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.writer.Write(line)
+	return err
+}
+
+// resolverNodeKey 上下文中记录语句执行节点的键
+type resolverNodeKey struct{}
+
+// registerResolverNodeCallbacks 注册记录语句执行节点的回调
+// 在dbresolver切换连接池之后，按语句实际使用的连接池判断是否为从库；事务中的语句不切换连接池，不做记录
+// 参数:
+//   - db: 数据库实例
+//   - replicas: 从库连接池
+// 返回值:
+//   - error: 错误信息
+func registerResolverNodeCallbacks(db *gorm.DB, replicas []*sql.DB) error {
+	const name = "database:resolver_node"
+	mark := func(tx *gorm.DB) {
+		pool := tx.Statement.ConnPool
+		if _, ok := pool.(gorm.TxCommitter); ok {
+			return
+		}
+		if prepared, ok := pool.(*gorm.PreparedStmtDB); ok {
+			pool = prepared.ConnPool
 		}
-		
-		s.logger.Printf("[SLOW_QUERY] %s", logInfo)
-		
-		// 同时通过基础日志记录器记录
-		if s.baseLogger != nil {
-			s.baseLogger.Warn(ctx, "检测到慢查询", "duration", elapsed, "sql", sql, "rows", rows)
+
+		node := "master"
+		for _, replica := range replicas {
+			if pool == gorm.ConnPool(replica) {
+				node = "slave"
+				break
+			}
 		}
+		tx.Statement.Context = context.WithValue(tx.Statement.Context, resolverNodeKey{}, node)
+	}
+
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:db_resolver").Register(name, mark); err != nil {
+		return err
+	}
+	if err := callbacks.Query().After("gorm:db_resolver").Register(name, mark); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:db_resolver").Register(name, mark); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:db_resolver").Register(name, mark); err != nil {
+		return err
+	}
+	if err := callbacks.Row().After("gorm:db_resolver").Register(name, mark); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:db_resolver").Register(name, mark)
+}
+
+// splitResolverMode 解析执行节点并去除dbresolver添加的SQL前缀
+// 执行节点由registerResolverNodeCallbacks记录，dbresolver切换连接池时会在SQL前添加对应的前缀
+// 参数:
+//   - ctx: 上下文
+//   - sql: SQL语句
+// 返回值:
+//   - string: 执行节点 (master, slave)
+//   - string: 去除前缀后的SQL
+func splitResolverMode(ctx context.Context, sql string) (string, string) {
+	switch ctx.Value(resolverNodeKey{}) {
+	case "slave":
+		return "slave", strings.TrimPrefix(sql, "[replica] ")
+	case "master":
+		return "master", strings.TrimPrefix(sql, "[source] ")
+	default:
+		return "master", sql
 	}
 }
 
@@ -234,7 +430,7 @@ func (z *ZapLogger) Info(ctx context.Context, msg string, data ...interface{}) {
 	if z.logLevel >= Info {
 		// 这里应该调用zap的Info方法
 		// 为了避免强依赖，这里使用反射或类型断言
-		fmt.Printf("[ZAP-INFO] %s %v\n", msg, data)
+		fmt.Printf("[ZAP-INFO] %s %v%s\n", msg, data, formatLogFields(LogFieldsFromContext(ctx)))
 	}
 }
 
@@ -246,7 +442,7 @@ func (z *ZapLogger) Info(ctx context.Context, msg string, data ...interface{}) {
 func (z *ZapLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
 	if z.logLevel >= Warn {
 		// 这里应该调用zap的Warn方法
-		fmt.Printf("[ZAP-WARN] %s %v\n", msg, data)
+		fmt.Printf("[ZAP-WARN] %s %v%s\n", msg, data, formatLogFields(LogFieldsFromContext(ctx)))
 	}
 }
 
@@ -258,7 +454,7 @@ func (z *ZapLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
 func (z *ZapLogger) Error(ctx context.Context, msg string, data ...interface{}) {
 	if z.logLevel >= Error {
 		// 这里应该调用zap的Error方法
-		fmt.Printf("[ZAP-ERROR] %s %v\n", msg, data)
+		fmt.Printf("[ZAP-ERROR] %s %v%s\n", msg, data, formatLogFields(LogFieldsFromContext(ctx)))
 	}
 }
 
@@ -275,14 +471,330 @@ func (z *ZapLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql s
 
 	elapsed := time.Since(begin)
 	sql, rows := fc()
+	fields := formatLogFields(LogFieldsFromContext(ctx))
 
 	// 使用zap记录结构化日志
 	switch {
 	case err != nil && z.logLevel >= Error:
-		fmt.Printf("[ZAP-ERROR] SQL执行失败: duration=%v, rows=%d, sql=%s, error=%v\n", elapsed, rows, sql, err)
+		fmt.Printf("[ZAP-ERROR] SQL执行失败: duration=%v, rows=%d, sql=%s, error=%v%s\n", elapsed, rows, sql, err, fields)
 	case elapsed > 200*time.Millisecond && z.logLevel >= Warn:
-		fmt.Printf("[ZAP-WARN] 慢查询检测: duration=%v, rows=%d, sql=%s\n", elapsed, rows, sql)
+		fmt.Printf("[ZAP-WARN] 慢查询检测: duration=%v, rows=%d, sql=%s%s\n", elapsed, rows, sql, fields)
 	case z.logLevel == Info:
-		fmt.Printf("[ZAP-INFO] SQL执行: duration=%v, rows=%d, sql=%s\n", elapsed, rows, sql)
+		fmt.Printf("[ZAP-INFO] SQL执行: duration=%v, rows=%d, sql=%s%s\n", elapsed, rows, sql, fields)
+	}
+}
+
+// gormLogger GORM日志桥接器
+// 将Logger接口适配为gorm的logger.Interface，使SQL日志经由注入的日志记录器输出
+type gormLogger struct {
+	// logger 实际输出日志的记录器
+	logger Logger
+	// logLevel 当前日志级别
+	logLevel LogLevel
+	// config 日志配置
+	config LogConfig
+	// redactor SQL脱敏器，为nil时不脱敏
+	redactor *redactor
+	// sampler SQL日志采样器，为nil时不采样
+	sampler *sampler
+	// slowQueryLogger 慢查询日志记录器，不受日志级别和采样影响
+	slowQueryLogger Logger
+}
+
+// newGormLogger 创建GORM日志桥接器
+// 参数:
+//   - base: 日志记录器
+//   - config: 日志配置
+//   - r: SQL脱敏器，可为nil
+// 返回值:
+//   - *gormLogger: GORM日志桥接器
+func newGormLogger(base Logger, config LogConfig, r *redactor) *gormLogger {
+	level := parseLogLevel(config.Level)
+	return &gormLogger{
+		logger:   base.LogMode(level),
+		logLevel: level,
+		config:   config,
+		redactor: r,
+	}
+}
+
+// LogMode 设置日志模式
+// 参数:
+//   - level: GORM日志级别
+// 返回值:
+//   - gormlogger.Interface: GORM日志接口
+func (g *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	newLogger := *g
+	newLogger.logLevel = fromGormLogLevel(level)
+	newLogger.logger = g.logger.LogMode(newLogger.logLevel)
+	return &newLogger
+}
+
+// Info 记录信息级别日志
+// 参数:
+//   - ctx: 上下文
+//   - msg: 日志消息
+//   - data: 附加数据
+func (g *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	g.logger.Info(ctx, msg, data...)
+}
+
+// Warn 记录警告级别日志
+// 参数:
+//   - ctx: 上下文
+//   - msg: 日志消息
+//   - data: 附加数据
+func (g *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	g.logger.Warn(ctx, msg, data...)
+}
+
+// Error 记录错误级别日志
+// 参数:
+//   - ctx: 上下文
+//   - msg: 日志消息
+//   - data: 附加数据
+func (g *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	g.logger.Error(ctx, msg, data...)
+}
+
+// Trace 记录SQL执行轨迹
+// 参数:
+//   - ctx: 上下文
+//   - begin: 开始时间
+//   - fc: 获取SQL和影响行数的函数
+//   - err: 执行错误
+func (g *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	logSQL := g.logLevel > Silent
+	if !logSQL && g.slowQueryLogger == nil {
+		return
+	}
+
+	// 按配置忽略记录未找到的错误
+	if err != nil && g.config.IgnoreRecordNotFoundError && errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+
+	if g.redactor != nil {
+		traceFn := fc
+		fc = func() (string, int64) {
+			sql, rows := traceFn()
+			return g.redactor.redactSQL(sql), rows
+		}
+		// 驱动错误可能包含敏感列的值，如唯一键冲突
+		err = g.redactor.redactError(err)
+	}
+
+	// 慢查询记录与SQL日志共用一次求值结果
+	var (
+		once       sync.Once
+		sql        string
+		rows       int64
+		evaluateFn = fc
+	)
+	fc = func() (string, int64) {
+		once.Do(func() {
+			sql, rows = evaluateFn()
+		})
+		return sql, rows
+	}
+
+	if g.slowQueryLogger != nil {
+		g.slowQueryLogger.Trace(ctx, begin, fc, err)
+	}
+
+	if !logSQL {
+		return
+	}
+
+	if g.sampler != nil {
+		// 采样需要SQL指纹
+		sql, _ := fc()
+		if !g.sampler.allow(sql, time.Since(begin), err) {
+			return
+		}
+	}
+
+	g.logger.Trace(ctx, begin, fc, err)
+}
+
+// ParamsFilter 过滤SQL参数
+// 启用参数化查询时，日志中只保留占位符而不展开参数值；启用脱敏时替换敏感列的参数值
+// 参数:
+//   - ctx: 上下文
+//   - sql: SQL语句
+//   - params: 绑定参数
+// 返回值:
+//   - string: SQL语句
+//   - []interface{}: 绑定参数
+func (g *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if g.config.ParameterizedQueries {
+		return sql, nil
+	}
+	if g.redactor != nil {
+		return sql, g.redactor.redactVars(sql, params)
+	}
+	return sql, params
+}
+
+// reloadableLogger 可替换的GORM日志记录器
+// 安装到gorm.Config后保持不变，重载配置时原子替换内部实现，
+// 因此dbresolver等插件持有的引用同样能看到新配置
+type reloadableLogger struct {
+	// current 当前的日志实现
+	current atomic.Value
+}
+
+// loggerHolder 包装日志实现，保证atomic.Value中存储的具体类型一致
+type loggerHolder struct {
+	// logger GORM日志接口
+	logger gormlogger.Interface
+}
+
+// newReloadableLogger 创建可替换的GORM日志记录器
+// 参数:
+//   - l: 初始日志实现
+// 返回值:
+//   - *reloadableLogger: 可替换的日志记录器
+func newReloadableLogger(l gormlogger.Interface) *reloadableLogger {
+	r := &reloadableLogger{}
+	r.store(l)
+	return r
+}
+
+// store 替换日志实现
+// 参数:
+//   - l: 新的日志实现
+func (r *reloadableLogger) store(l gormlogger.Interface) {
+	r.current.Store(loggerHolder{logger: l})
+}
+
+// load 获取当前日志实现
+// 返回值:
+//   - gormlogger.Interface: 当前日志实现
+func (r *reloadableLogger) load() gormlogger.Interface {
+	return r.current.Load().(loggerHolder).logger
+}
+
+// redactor 获取当前日志实现使用的脱敏器
+// 返回值:
+//   - *redactor: 脱敏器，未启用时为nil
+func (r *reloadableLogger) redactor() *redactor {
+	if g, ok := r.load().(*gormLogger); ok {
+		return g.redactor
+	}
+	return nil
+}
+
+// LogMode 设置日志模式
+// 返回基于当前实现的副本，之后的重载不影响该副本（如db.Debug()）
+// 参数:
+//   - level: GORM日志级别
+// 返回值:
+//   - gormlogger.Interface: GORM日志接口
+func (r *reloadableLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return r.load().LogMode(level)
+}
+
+// Info 记录信息级别日志
+// 参数:
+//   - ctx: 上下文
+//   - msg: 日志消息
+//   - data: 附加数据
+func (r *reloadableLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	r.load().Info(ctx, msg, data...)
+}
+
+// Warn 记录警告级别日志
+// 参数:
+//   - ctx: 上下文
+//   - msg: 日志消息
+//   - data: 附加数据
+func (r *reloadableLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	r.load().Warn(ctx, msg, data...)
+}
+
+// Error 记录错误级别日志
+// 参数:
+//   - ctx: 上下文
+//   - msg: 日志消息
+//   - data: 附加数据
+func (r *reloadableLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	r.load().Error(ctx, msg, data...)
+}
+
+// Trace 记录SQL执行轨迹
+// 参数:
+//   - ctx: 上下文
+//   - begin: 开始时间
+//   - fc: 获取SQL和影响行数的函数
+//   - err: 执行错误
+func (r *reloadableLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	r.load().Trace(ctx, begin, fc, err)
+}
+
+// ParamsFilter 过滤SQL参数，交由当前日志实现处理
+// 参数:
+//   - ctx: 上下文
+//   - sql: SQL语句
+//   - params: 绑定参数
+// 返回值:
+//   - string: SQL语句
+//   - []interface{}: 绑定参数
+func (r *reloadableLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if filter, ok := r.load().(gorm.ParamsFilter); ok {
+		return filter.ParamsFilter(ctx, sql, params...)
+	}
+	return sql, params
+}
+
+// parseLogLevel 解析日志级别字符串
+// 参数:
+//   - level: 日志级别 (silent, error, warn, info)
+// 返回值:
+//   - LogLevel: 日志级别，无法识别时默认为Info
+func parseLogLevel(level string) LogLevel {
+	switch strings.ToLower(level) {
+	case "silent":
+		return Silent
+	case "error":
+		return Error
+	case "warn":
+		return Warn
+	case "info":
+		return Info
+	default:
+		return Info
+	}
+}
+
+// isValidLogLevel 判断日志级别字符串是否合法，空字符串表示使用默认级别
+// 参数:
+//   - level: 日志级别
+// 返回值:
+//   - bool: 是否合法
+func isValidLogLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "", "silent", "error", "warn", "info":
+		return true
+	default:
+		return false
 	}
-}
\ No newline at end of file
+}
+
+// fromGormLogLevel 将GORM日志级别转换为LogLevel
+// 参数:
+//   - level: GORM日志级别
+// 返回值:
+//   - LogLevel: 日志级别
+func fromGormLogLevel(level gormlogger.LogLevel) LogLevel {
+	switch level {
+	case gormlogger.Silent:
+		return Silent
+	case gormlogger.Error:
+		return Error
+	case gormlogger.Warn:
+		return Warn
+	default:
+		return Info
+	}
+}
@@ -2,8 +2,10 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gorm.io/gorm"
@@ -79,8 +81,12 @@ type Manager interface {
 	GetMasterDB() *gorm.DB
 	// GetSlaveDB 获取从库实例
 	GetSlaveDB() *gorm.DB
+	// DB 获取上下文中的活动事务，不存在时返回主从分离的数据库实例
+	DB(ctx context.Context) *gorm.DB
 	// Transaction 执行事务
 	Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error
+	// TransactionWithOptions 按选项执行事务，支持死锁和序列化失败时自动重试
+	TransactionWithOptions(ctx context.Context, opts TxOptions, fn func(tx *gorm.DB) error) error
 	// HealthCheck 健康检查
 	HealthCheck(ctx context.Context) map[string]HealthStatus
 	// GetStats 获取数据库统计信息
@@ -89,6 +95,14 @@ type Manager interface {
 	Close() error
 	// Ping 测试数据库连接
 	Ping(ctx context.Context) error
+	// Reload 重新加载配置
+	Reload(config *Config) error
+	// WatchConfig 监视配置文件并在变化时自动重载
+	WatchConfig(ctx context.Context, path string, interval time.Duration, envPrefix ...string) error
+	// ActiveTransactions 获取进行中的事务
+	ActiveTransactions() []TransactionInfo
+	// TransactionStats 获取事务统计信息
+	TransactionStats() TransactionStats
 }
 
 // DBManager 数据库管理器实现
@@ -106,13 +120,29 @@ type DBManager struct {
 	// lastHealthCheck 最后健康检查时间
 	lastHealthCheck time.Time
 	// slowQueryLogger 慢查询日志记录器
-	slowQueryLogger Logger
+	slowQueryLogger *SlowQueryLogger
+	// gormLogger 安装到GORM的日志记录器，重载配置时替换其内部实现
+	gormLogger *reloadableLogger
+	// replicaPools 从库连接池，重建主从分离或关闭时释放
+	replicaPools []*sql.DB
+	// monitorCancel 停止当前的监控协程
+	monitorCancel context.CancelFunc
+	// reloadMu 串行化配置重载
+	reloadMu sync.Mutex
+	// activeTxs 进行中的事务
+	activeTxs sync.Map
+	// txSeq 事务ID序列
+	txSeq atomic.Uint64
+	// txMetrics 事务计数器
+	txMetrics txMetrics
 	// ctx 上下文
 	ctx context.Context
 	// cancel 取消函数
 	cancel context.CancelFunc
 	// wg 等待组，用于优雅关闭
 	wg sync.WaitGroup
+	// closed 管理器是否已关闭，由mu保护，关闭后不再启动新协程
+	closed bool
 }
 
 // NewManager 创建新的数据库管理器实例
@@ -150,66 +180,202 @@ func NewManager(config *Config, logger ...Logger) (Manager, error) {
 	}
 
 	// 设置慢查询日志记录器
-	manager.slowQueryLogger = manager.newSlowQueryLogger()
+	slowQueryLogger, err := newSlowQueryLogger(config.SlowQueryConfig, manager.logger, config.Type)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create slow query logger: %w", err)
+	}
+	manager.slowQueryLogger = slowQueryLogger
 
 	// 初始化数据库连接
 	if err := manager.initDB(); err != nil {
 		cancel()
+		slowQueryLogger.Close()
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
 	// 启动监控
 	if config.MonitorConfig.Enabled {
-		manager.startMonitoring()
+		manager.startMonitoring(config.MonitorConfig)
 	}
 
 	return manager, nil
 }
 
 // validateConfig 验证配置的有效性
+// 检查所有配置项并解析主从库DSN，发现的问题全部收集到ConfigErrors中返回
 // 参数:
 //   - config: 数据库配置
 // 返回值:
-//   - error: 验证错误信息
+//   - error: 验证错误信息，类型为ConfigErrors
 func validateConfig(config *Config) error {
-	if config.Master == "" {
-		return fmt.Errorf("master database DSN cannot be empty")
+	var errs ConfigErrors
+	addError := func(key, format string, args ...interface{}) {
+		errs = append(errs, newConfigError(key, format, args...))
 	}
 
+	// 验证主库配置
+	masterType := normalizeDBType(config.Type)
 	if config.Type == "" {
-		return fmt.Errorf("database type cannot be empty")
+		addError("type", "database type cannot be empty")
+	} else if masterType == "" {
+		addError("type", "unsupported database type: %s", config.Type)
 	}
 
-	// 验证连接池配置
-	if config.PoolConfig.MaxOpenConns < 0 {
-		return fmt.Errorf("max open connections cannot be negative")
+	switch {
+	case config.Master == "" && config.Connection.IsZero():
+		addError("master", "master database DSN cannot be empty")
+	case config.Master != "" && !config.Connection.IsZero():
+		addError("connection", "master DSN and connection cannot both be set")
+	case masterType != "":
+		key := "master"
+		if config.Master == "" {
+			key = "connection"
+		}
+		if err := validateDSN(config.Master, config.Connection, config.Type); err != nil {
+			errs = append(errs, &ConfigError{Key: key, Err: err})
+		}
+	}
+	if err := validateCredentials(config.Credentials, config.Type); err != nil {
+		errs = append(errs, &ConfigError{Key: "credentials", Err: err})
 	}
+	if err := validateInitStatements(config.InitStatements, config.Type); err != nil {
+		errs = append(errs, &ConfigError{Key: "init_statements", Err: err})
+	}
+	if err := config.TLS.validate(config.Type); err != nil {
+		errs = append(errs, &ConfigError{Key: "tls", Err: err})
+	}
+
+	// 验证从库配置，未指定类型的从库沿用主库类型
+	for i, slave := range config.Slaves {
+		key := fmt.Sprintf("slaves[%d]", i)
+
+		slaveType := slave.Type
+		if slaveType == "" {
+			slaveType = config.Type
+		}
+		normalized := normalizeDBType(slaveType)
+		if normalized == "" {
+			if slave.Type != "" {
+				addError(key+".type", "unsupported database type: %s", slave.Type)
+			}
+		} else if masterType != "" && normalized != masterType {
+			addError(key+".type", "slave database type %s does not match master type %s", slave.Type, config.Type)
+		}
 
-	if config.PoolConfig.MaxIdleConns < 0 {
-		return fmt.Errorf("max idle connections cannot be negative")
+		if slave.Weight < 0 {
+			addError(key+".weight", "slave weight cannot be negative")
+		}
+
+		switch {
+		case slave.DSN == "" && slave.Connection.IsZero():
+			addError(key+".dsn", "slave database DSN cannot be empty")
+		case slave.DSN != "" && !slave.Connection.IsZero():
+			addError(key+".connection", "slave DSN and connection cannot both be set")
+		case normalized != "":
+			dsnKey := key + ".dsn"
+			if slave.DSN == "" {
+				dsnKey = key + ".connection"
+			}
+			if err := validateDSN(slave.DSN, slave.Connection, slaveType); err != nil {
+				errs = append(errs, &ConfigError{Key: dsnKey, Err: err})
+			}
+		}
+		if err := validateCredentials(slave.Credentials, slaveType); err != nil {
+			errs = append(errs, &ConfigError{Key: key + ".credentials", Err: err})
+		}
+		if err := validateInitStatements(slave.InitStatements, slaveType); err != nil {
+			errs = append(errs, &ConfigError{Key: key + ".init_statements", Err: err})
+		}
+		if err := slave.TLS.validate(slaveType); err != nil {
+			errs = append(errs, &ConfigError{Key: key + ".tls", Err: err})
+		}
+
+		errs = append(errs, validatePoolConfig(key+".pool_config", slave.PoolConfig)...)
 	}
 
-	if config.PoolConfig.MaxIdleConns > config.PoolConfig.MaxOpenConns && config.PoolConfig.MaxOpenConns > 0 {
-		return fmt.Errorf("max idle connections cannot be greater than max open connections")
+	// 验证连接池配置
+	errs = append(errs, validatePoolConfig("pool_config", config.PoolConfig)...)
+
+	// 验证日志配置
+	if !isValidLogLevel(config.LogConfig.Level) {
+		addError("log_config.level", "unknown log level %q, expected one of silent, error, warn, info", config.LogConfig.Level)
 	}
 
 	// 验证慢查询配置
 	if config.SlowQueryConfig.Enabled && config.SlowQueryConfig.Threshold <= 0 {
-		return fmt.Errorf("slow query threshold must be positive when enabled")
+		addError("slow_query_config.threshold", "slow query threshold must be positive when enabled")
+	}
+	if config.SlowQueryConfig.MaxSize < 0 || config.SlowQueryConfig.MaxBackups < 0 || config.SlowQueryConfig.MaxAge < 0 {
+		addError("slow_query_config", "slow query log rotation settings cannot be negative")
+	}
+
+	// 验证采样配置
+	if sampling := config.LogConfig.Sampling; sampling.Enabled {
+		if sampling.Initial < 0 || sampling.Thereafter < 0 {
+			addError("log_config.sampling", "log sampling initial and thereafter cannot be negative")
+		}
+		if sampling.Interval < 0 {
+			addError("log_config.sampling.interval", "log sampling interval cannot be negative")
+		}
+	}
+
+	// 验证脱敏配置
+	if _, err := newRedactor(config.LogConfig.Redact); err != nil {
+		errs = append(errs, &ConfigError{Key: "log_config.redact.patterns", Err: err})
 	}
 
 	// 验证监控配置
 	if config.MonitorConfig.Enabled {
 		if config.MonitorConfig.HealthCheckInterval <= 0 {
-			return fmt.Errorf("health check interval must be positive when monitoring is enabled")
+			addError("monitor_config.health_check_interval", "health check interval must be positive when monitoring is enabled")
 		}
 		if config.MonitorConfig.ConnectionTimeout <= 0 {
-			return fmt.Errorf("connection timeout must be positive when monitoring is enabled")
+			addError("monitor_config.connection_timeout", "connection timeout must be positive when monitoring is enabled")
 		}
 		if config.MonitorConfig.MaxRetries < 0 {
-			return fmt.Errorf("max retries cannot be negative")
+			addError("monitor_config.max_retries", "max retries cannot be negative")
 		}
 	}
 
+	// 验证事务配置
+	if config.TransactionConfig.Timeout < 0 {
+		addError("transaction_config.timeout", "transaction timeout cannot be negative")
+	}
+	if config.TransactionConfig.LongRunningThreshold < 0 {
+		addError("transaction_config.long_running_threshold", "long running transaction threshold cannot be negative")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// validatePoolConfig 验证连接池配置
+// 参数:
+//   - key: 配置项路径
+//   - config: 连接池配置
+// 返回值:
+//   - ConfigErrors: 验证错误
+func validatePoolConfig(key string, config PoolConfig) ConfigErrors {
+	var errs ConfigErrors
+
+	if config.MaxOpenConns < 0 {
+		errs = append(errs, newConfigError(key+".max_open_conns", "max open connections cannot be negative"))
+	}
+
+	if config.MaxIdleConns < 0 {
+		errs = append(errs, newConfigError(key+".max_idle_conns", "max idle connections cannot be negative"))
+	}
+
+	if config.MaxIdleConns > config.MaxOpenConns && config.MaxOpenConns > 0 {
+		errs = append(errs, newConfigError(key+".max_idle_conns", "max idle connections cannot be greater than max open connections"))
+	}
+
+	if config.ConnMaxLifetime < 0 || config.ConnMaxIdleTime < 0 {
+		errs = append(errs, newConfigError(key, "connection lifetime and idle time cannot be negative"))
+	}
+
+	return errs
+}
@@ -1,15 +1,14 @@
 package database
 
 import (
-	"context"	
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"math/rand/v2"
 	"os"
 	"time"
 
-	"gorm.io/driver/mysql"
-	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"gorm.io/plugin/dbresolver"
@@ -39,40 +38,30 @@ func (m *DBManager) GetSlaveDB() *gorm.DB {
 	return m.db.Clauses(dbresolver.Read)
 }
 
+// DB 获取当前上下文应使用的数据库实例
+// 上下文中存在由Transaction开启的活动事务时返回该事务，否则返回GetDB()的主从分离实例；
+// 返回的实例均携带ctx，仓储代码只需传递ctx即可自动加入调用方的事务
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - *gorm.DB: 数据库实例
+func (m *DBManager) DB(ctx context.Context) *gorm.DB {
+	if value := m.txFromContext(ctx); value != nil {
+		return value.tx.WithContext(ctx)
+	}
+	return m.GetDB().WithContext(ctx)
+}
+
 // Transaction 执行事务
+// 事务保存在传给fn的tx所携带的上下文中（tx.Statement.Context），通过DB方法获取；使用该上下文再次调用Transaction时，
+// 不会开启新事务，而是在当前事务中创建保存点，fn返回错误或panic时只回滚到该保存点
 // 参数:
 //   - ctx: 上下文
 //   - fn: 事务执行函数
 // 返回值:
 //   - error: 错误信息
 func (m *DBManager) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
-	if fn == nil {
-		return fmt.Errorf("transaction function cannot be nil")
-	}
-
-	// 使用主库执行事务
-	tx := m.GetMasterDB().WithContext(ctx).Begin()
-	if tx.Error != nil {
-		return fmt.Errorf("failed to begin transaction: %w", tx.Error)
-	}
-
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			panic(r)
-		}
-	}()
-
-	if err := fn(tx); err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	return nil
+	return m.TransactionWithOptions(ctx, TxOptions{}, fn)
 }
 
 // HealthCheck 健康检查
@@ -178,15 +167,34 @@ func (m *DBManager) GetStats() map[string]DatabaseStats {
 // 返回值:
 //   - error: 错误信息
 func (m *DBManager) Close() error {
+	// 标记为已关闭并取消上下文，停止监控、配置监视和后台任务
+	// wg.Add均在持有m.mu且未关闭时调用，标记后不会再有新协程加入；
+	// 持有reloadMu等待进行中的重载完成，之后的重载直接返回错误，不会再打开新的从库连接池。
+	// 标记后即释放reloadMu，避免与等待重载返回的配置监视协程互相等待
+	m.reloadMu.Lock()
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// 取消上下文，停止监控
+	m.closed = true
+	m.mu.Unlock()
+	m.reloadMu.Unlock()
 	m.cancel()
 
-	// 等待所有协程结束
+	// 等待所有协程结束，协程可能需要获取锁，因此在加锁前等待
 	m.wg.Wait()
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 关闭慢查询日志文件
+	if m.slowQueryLogger != nil {
+		if err := m.slowQueryLogger.Close(); err != nil {
+			m.logger.Error(m.ctx, "failed to close slow query log: %v", err)
+		}
+	}
+
+	// 关闭从库连接池
+	closeDBs(m.replicaPools)
+	m.replicaPools = nil
+
 	// 关闭数据库连接
 	if m.db != nil {
 		if sqlDB, err := m.db.DB(); err == nil {
@@ -197,6 +205,40 @@ func (m *DBManager) Close() error {
 	return nil
 }
 
+// Go 在管理器生命周期内运行后台任务
+// fn收到的ctx在管理器关闭时取消，Close会等待fn返回后再关闭连接池。
+// 不属于Manager接口，outbox中继等扩展通过类型断言使用
+// 参数:
+//   - fn: 后台任务
+// 返回值:
+//   - error: 管理器已关闭时返回错误
+func (m *DBManager) Go(fn func(ctx context.Context)) error {
+	if fn == nil {
+		return fmt.Errorf("background function cannot be nil")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return fmt.Errorf("database manager is closed")
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		fn(m.ctx)
+	}()
+	return nil
+}
+
+// Logger 获取日志记录器
+// 与Go一样不属于Manager接口，供扩展通过类型断言使用
+// 返回值:
+//   - Logger: 日志记录器
+func (m *DBManager) Logger() Logger {
+	return m.logger
+}
+
 // Ping 测试数据库连接
 // 测试主库连接是否正常
 // 参数:
@@ -204,7 +246,7 @@ func (m *DBManager) Close() error {
 // 返回值:
 //   - error: 错误信息
 func (m *DBManager) Ping(ctx context.Context) error {
-	sqlDB, err := m.db.DB()
+	sqlDB, err := m.GetDB().DB()
 	if err != nil {
 		return fmt.Errorf("failed to get sql.DB: %w", err)
 	}
@@ -217,56 +259,114 @@ func (m *DBManager) Ping(ctx context.Context) error {
 // 返回值:
 //   - error: 错误信息
 func (m *DBManager) initDB() error {
+	// 初始化SQL日志脱敏
+	r, err := newRedactor(m.config.LogConfig.Redact)
+	if err != nil {
+		return err
+	}
+	m.gormLogger = newReloadableLogger(m.createGormLogger(m.config, r, m.slowQueryLogger))
+
+	m.db, m.replicaPools, err = m.openDB(m.config, nil)
+	return err
+}
+
+// openDB 打开GORM实例并配置连接池与主从分离
+// 参数:
+//   - config: 数据库配置
+//   - masterPool: 复用的主库连接池，为nil时新建；重载从库配置时复用以保持主库连接不变
+// 返回值:
+//   - *gorm.DB: GORM数据库实例
+//   - []*sql.DB: 从库连接池
+//   - error: 错误信息
+func (m *DBManager) openDB(config *Config, masterPool gorm.ConnPool) (*gorm.DB, []*sql.DB, error) {
 	// 根据数据库类型选择驱动
-	dialector, err := m.getDialector(m.config.Master, m.config.Type)
+	dialector, err := m.getDialector(config.masterEndpoint(), masterPool)
 	if err != nil {
-		return fmt.Errorf("failed to get dialector for master: %w", err)
+		return nil, nil, fmt.Errorf("failed to get dialector for master: %w", err)
 	}
 
 	// 配置GORM
 	gormConfig := &gorm.Config{
-		Logger: m.createGormLogger(),
+		Logger: m.gormLogger,
 	}
 
 	// 打开数据库连接
-	m.db, err = gorm.Open(dialector, gormConfig)
+	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
-		return fmt.Errorf("failed to connect to master database: %w", err)
+		return nil, nil, fmt.Errorf("failed to connect to master database: %w", err)
+	}
+
+	if err := registerRedactCallbacks(db, m.gormLogger.redactor); err != nil {
+		return nil, nil, fmt.Errorf("failed to register redact callbacks: %w", err)
+	}
+	if err := registerTxCallbacks(db); err != nil {
+		return nil, nil, fmt.Errorf("failed to register transaction callbacks: %w", err)
 	}
 
-	// 配置连接池
-	if err := m.configureConnectionPool(m.db, m.config.PoolConfig); err != nil {
-		return fmt.Errorf("failed to configure master connection pool: %w", err)
+	// 配置连接池，复用的主库连接池保持现有设置
+	if masterPool == nil {
+		if err := m.configureConnectionPool(db, config.PoolConfig); err != nil {
+			return nil, nil, fmt.Errorf("failed to configure master connection pool: %w", err)
+		}
 	}
 
 	// 配置主从分离
-	if len(m.config.Slaves) > 0 {
-		if err := m.configureDBResolver(); err != nil {
-			return fmt.Errorf("failed to configure db resolver: %w", err)
+	var replicaPools []*sql.DB
+	if len(config.Slaves) > 0 {
+		if replicaPools, err = m.configureDBResolver(db, config); err != nil {
+			return nil, nil, fmt.Errorf("failed to configure db resolver: %w", err)
 		}
 	}
 
-	return nil
+	return db, replicaPools, nil
 }
 
 // getDialector 根据数据库类型获取对应的方言
+// 未提供DSN时由结构化连接配置生成；配置了凭据、初始化语句、TLS或DSN中包含 ${VAR} 引用时，
+// 通过连接器在每次建立连接时重新生成DSN、应用TLS配置并执行初始化语句
 // 参数:
-//   - dsn: 数据源名称
-//   - dbType: 数据库类型
+//   - ep: 节点连接参数
+//   - pool: 复用的连接池，为nil时由方言新建
 // 返回值:
 //   - gorm.Dialector: GORM方言
 //   - error: 错误信息
-func (m *DBManager) getDialector(dsn, dbType string) (gorm.Dialector, error) {
-	switch dbType {
-	case "mysql":
-		return mysql.Open(dsn), nil
-	case "postgres", "postgresql":
-		return postgres.Open(dsn), nil
-	case "sqlite", "sqlite3":
-		return sqlite.Open(dsn), nil
-	default:
-		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+func (m *DBManager) getDialector(ep endpoint, pool gorm.ConnPool) (gorm.Dialector, error) {
+	d := lookupDialect(ep.dbType)
+	if d == nil {
+		return nil, fmt.Errorf("unsupported database type: %s", ep.dbType)
+	}
+
+	conn := ep.conn
+	provider := credentialProvider(ep.creds, conn)
+	if provider != nil {
+		// 密码由提供者在连接时给出，不写入DSN
+		conn.Password = ""
 	}
+
+	dsn, err := resolveDSN(ep.dsn, conn, ep.dbType)
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有database/sql驱动的自定义方言在启动时一次性展开 ${VAR}
+	needConnector := provider != nil || len(ep.initStatements) > 0 || !ep.tls.IsZero() ||
+		(hasEnvReference(dsn) && d.driverName != "")
+	if pool == nil && needConnector {
+		c, err := newConnector(ep.dbType, dsn, provider, ep.initStatements, ep.tls)
+		if err != nil {
+			return nil, err
+		}
+		pool = sql.OpenDB(c)
+	}
+	if hasEnvReference(dsn) {
+		dsn = expandEnv(dsn)
+	}
+
+	dialector := d.factory(dsn)
+	if pool != nil {
+		return withConnPool(ep.dbType, dialector, pool)
+	}
+	return dialector, nil
 }
 
 // configureConnectionPool 配置连接池
@@ -281,118 +381,207 @@ func (m *DBManager) configureConnectionPool(db *gorm.DB, config PoolConfig) erro
 		return err
 	}
 
-	// 设置连接池参数
-	if config.MaxOpenConns > 0 {
-		sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	applyPoolConfig(sqlDB, config)
+	return nil
+}
+
+// defaultMaxIdleConns database/sql默认的最大空闲连接数
+const defaultMaxIdleConns = 2
+
+// applyPoolConfig 设置连接池参数
+// 未配置的项恢复为database/sql默认值，因此重载配置时可以撤销之前的设置
+// 参数:
+//   - sqlDB: 连接池
+//   - config: 连接池配置
+func applyPoolConfig(sqlDB *sql.DB, config PoolConfig) {
+	maxIdleConns := config.MaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = defaultMaxIdleConns
 	}
 
-	if config.MaxIdleConns > 0 {
-		sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+}
+
+// closeDBs 关闭连接池，等待进行中的查询完成
+// 参数:
+//   - pools: 连接池列表
+func closeDBs(pools []*sql.DB) {
+	for _, pool := range pools {
+		pool.Close()
 	}
+}
 
-	if config.ConnMaxLifetime > 0 {
-		sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+// replicaDialector 从库方言包装器
+// dbresolver打开从库时应用从库连接池配置，并记录连接池以便重建或关闭时释放
+type replicaDialector struct {
+	gorm.Dialector
+	// poolConfig 从库连接池配置
+	poolConfig PoolConfig
+	// pools 已打开的从库连接池
+	pools *[]*sql.DB
+}
+
+// Initialize 初始化从库连接
+// 参数:
+//   - db: 数据库实例
+// 返回值:
+//   - error: 错误信息
+func (d *replicaDialector) Initialize(db *gorm.DB) error {
+	if err := d.Dialector.Initialize(db); err != nil {
+		return err
+	}
+	if sqlDB, ok := db.ConnPool.(*sql.DB); ok {
+		applyPoolConfig(sqlDB, d.poolConfig)
+		*d.pools = append(*d.pools, sqlDB)
 	}
+	return nil
+}
+
+// weightedPolicy 按权重随机选择从库
+type weightedPolicy struct {
+	// weights 从库权重，与dbresolver中从库的顺序一致
+	weights []int
+	// total 权重之和
+	total int
+}
 
-	if config.ConnMaxIdleTime > 0 {
-		sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+// newWeightedPolicy 创建按权重随机选择从库的策略
+// 权重为0表示未设置，按1处理
+// 参数:
+//   - slaves: 从库配置
+// 返回值:
+//   - *weightedPolicy: 选择策略
+func newWeightedPolicy(slaves []SlaveConfig) *weightedPolicy {
+	p := &weightedPolicy{weights: make([]int, len(slaves))}
+	for i, slave := range slaves {
+		p.weights[i] = max(slave.Weight, 1)
+		p.total += p.weights[i]
 	}
+	return p
+}
 
-	return nil
+// Resolve 按权重随机选择连接池
+// 参数:
+//   - pools: 候选连接池
+// 返回值:
+//   - gorm.ConnPool: 选中的连接池
+func (p *weightedPolicy) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	// 候选与从库配置不对应时退化为均匀随机
+	if len(pools) != len(p.weights) {
+		return pools[rand.IntN(len(pools))]
+	}
+
+	n := rand.IntN(p.total)
+	for i, weight := range p.weights {
+		if n < weight {
+			return pools[i]
+		}
+		n -= weight
+	}
+	return pools[len(pools)-1]
 }
 
 // configureDBResolver 配置数据库解析器（主从分离）
+// 写操作使用db自身的主库连接池，读操作按从库权重随机分配
+// 参数:
+//   - db: 数据库实例
+//   - config: 数据库配置
 // 返回值:
+//   - []*sql.DB: 从库连接池
 //   - error: 错误信息
-func (m *DBManager) configureDBResolver() error {
+func (m *DBManager) configureDBResolver(db *gorm.DB, config *Config) ([]*sql.DB, error) {
 	// 准备从库配置
-	var replicas []gorm.Dialector
-	var sources []gorm.Dialector
+	var (
+		replicas []gorm.Dialector
+		pools    []*sql.DB
+	)
 
-	for _, slaveConfig := range m.config.Slaves {
-		dialector, err := m.getDialector(slaveConfig.DSN, slaveConfig.Type)
+	for i, slaveConfig := range config.Slaves {
+		dialector, err := m.getDialector(config.slaveEndpoint(slaveConfig), nil)
 		if err != nil {
-			return fmt.Errorf("failed to get dialector for slave %s: %w", slaveConfig.DSN, err)
+			return nil, fmt.Errorf("failed to get dialector for slave %d: %w", i, err)
 		}
-		replicas = append(replicas, dialector)
-	}
-
-	// 主库也作为源
-	masterDialector, err := m.getDialector(m.config.Master, m.config.Type)
-	if err != nil {
-		return fmt.Errorf("failed to get dialector for master: %w", err)
+		replicas = append(replicas, &replicaDialector{
+			Dialector:  dialector,
+			poolConfig: slaveConfig.PoolConfig,
+			pools:      &pools,
+		})
 	}
-	sources = append(sources, masterDialector)
 
 	// 配置DBResolver插件
 	resolverConfig := dbresolver.Config{
-		Sources:  sources,
-		Replicas: replicas,
-		Policy:   dbresolver.RandomPolicy{}, // 随机策略
+		Replicas:          replicas,
+		Policy:            newWeightedPolicy(config.Slaves), // 按权重随机
+		TraceResolverMode: true,
 	}
 
-	// 为每个从库配置连接池
-	for range m.config.Slaves {
-		resolverConfig.TraceResolverMode = true
+	if err := db.Use(dbresolver.Register(resolverConfig)); err != nil {
+		closeDBs(pools)
+		return nil, err
 	}
-
-	return m.db.Use(dbresolver.Register(resolverConfig))
+	if err := registerResolverNodeCallbacks(db, pools); err != nil {
+		closeDBs(pools)
+		return nil, err
+	}
+	return pools, nil
 }
 
 // createGormLogger 创建GORM日志记录器
+// SQL日志通过桥接器交由m.logger输出，因此NewManager传入的自定义日志记录器同样生效；
+// 慢查询记录不受LogConfig开关影响
+// 参数:
+//   - config: 数据库配置
+//   - r: SQL脱敏器，可为nil
+//   - slowQueryLogger: 慢查询日志记录器
 // 返回值:
 //   - logger.Interface: GORM日志接口
-func (m *DBManager) createGormLogger() logger.Interface {
-	if !m.config.LogConfig.Enabled {
+func (m *DBManager) createGormLogger(config *Config, r *redactor, slowQueryLogger *SlowQueryLogger) logger.Interface {
+	if !config.LogConfig.Enabled && !config.SlowQueryConfig.Enabled {
 		return logger.Discard
 	}
 
-	// 设置日志级别
-	var logLevel logger.LogLevel
-	switch m.config.LogConfig.Level {
-	case "silent":
-		logLevel = logger.Silent
-	case "error":
-		logLevel = logger.Error
-	case "warn":
-		logLevel = logger.Warn
-	case "info":
-		logLevel = logger.Info
-	default:
-		logLevel = logger.Info
-	}
-
-	// 创建自定义日志记录器
-	return logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             m.config.SlowQueryConfig.Threshold,
-			LogLevel:                  logLevel,
-			IgnoreRecordNotFoundError: m.config.LogConfig.IgnoreRecordNotFoundError,
-			ParameterizedQueries:      m.config.LogConfig.ParameterizedQueries,
-			Colorful:                  m.config.LogConfig.Colorful,
-		},
-	)
+	base := m.logger
+	if l, ok := base.(*DefaultLogger); ok {
+		// 默认日志记录器的慢查询警告随配置的慢查询阈值变化
+		base = l.withSlowThreshold(config.SlowQueryConfig.Threshold)
+	}
+	gormLogger := newGormLogger(base, config.LogConfig, r)
+	if !config.LogConfig.Enabled {
+		gormLogger.logLevel = Silent
+	}
+	gormLogger.sampler = newSampler(config.LogConfig.Sampling, config.SlowQueryConfig.Threshold, config.Type)
+	if config.SlowQueryConfig.Enabled {
+		gormLogger.slowQueryLogger = slowQueryLogger
+	}
+	return gormLogger
 }
 
 // startMonitoring 启动监控协程
-// 定期执行健康检查
-func (m *DBManager) startMonitoring() {
+// 定期执行健康检查，调用方需持有m.mu且管理器未关闭，或处于初始化阶段
+// 参数:
+//   - config: 监控配置
+func (m *DBManager) startMonitoring(config MonitorConfig) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.monitorCancel = cancel
+
 	m.wg.Add(1)
 	go func() {
 		defer m.wg.Done()
 
-		ticker := time.NewTicker(m.config.MonitorConfig.HealthCheckInterval)
+		ticker := time.NewTicker(config.HealthCheckInterval)
 		defer ticker.Stop()
 
 		for {
 			select {
-			case <-m.ctx.Done():
+			case <-ctx.Done():
 				return
 			case <-ticker.C:
 				// 执行健康检查
-				ctx, cancel := context.WithTimeout(context.Background(), m.config.MonitorConfig.ConnectionTimeout)
-				status := m.HealthCheck(ctx)
+				checkCtx, cancel := context.WithTimeout(context.Background(), config.ConnectionTimeout)
+				status := m.HealthCheck(checkCtx)
 				cancel()
 
 				// 记录不健康的数据库
@@ -406,23 +595,27 @@ func (m *DBManager) startMonitoring() {
 	}()
 }
 
+// stopMonitoring 停止监控协程，调用方需持有m.mu
+func (m *DBManager) stopMonitoring() {
+	if m.monitorCancel != nil {
+		m.monitorCancel()
+		m.monitorCancel = nil
+	}
+}
+
 // newDefaultLogger 创建默认日志记录器
+// 未启用日志时为Silent级别，不输出任何内容
 // 返回值:
 //   - Logger: 日志记录器接口
 func (m *DBManager) newDefaultLogger() Logger {
+	level := Silent
+	if m.config.LogConfig.Enabled {
+		level = parseLogLevel(m.config.LogConfig.Level)
+	}
 	return &DefaultLogger{
-		config: m.config.LogConfig,
-		logger: log.New(os.Stdout, "[DATABASE] ", log.LstdFlags),
+		config:        m.config.LogConfig,
+		logger:        log.New(os.Stdout, "[DATABASE] ", log.LstdFlags),
+		logLevel:      level,
+		slowThreshold: m.config.SlowQueryConfig.Threshold,
 	}
 }
-
-// newSlowQueryLogger 创建慢查询日志记录器
-// 返回值:
-//   - Logger: 慢查询日志记录器
-func (m *DBManager) newSlowQueryLogger() Logger {
-	return &SlowQueryLogger{
-		config:     m.config.SlowQueryConfig,
-		baseLogger: m.logger,
-		logger:     log.New(os.Stdout, "[SLOW_QUERY] ", log.LstdFlags),
-	}
-}
\ No newline at end of file
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// Option 数据库管理器选项
+type Option func(*options)
+
+// options NewManagerWithOptions 的构建参数
+type options struct {
+	// config 数据库配置
+	config *Config
+	// logger 日志记录器，为nil时使用默认日志记录器
+	logger Logger
+}
+
+// DefaultConfig 返回适合生产环境的默认配置
+// 默认值如下，Master与Type需由调用方设置:
+//   - 连接池: 最大连接数25，最大空闲连接数10，连接最长生命周期30分钟，最长空闲时间5分钟
+//   - 日志: 启用，级别warn，忽略记录未找到错误，日志中不展开参数值
+//   - 慢查询: 启用，阈值200毫秒，不记录参数值
+//   - 监控: 关闭，启用后每30秒检查一次，超时5秒，最多重试3次
+//
+// 返回值:
+//   - *Config: 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		PoolConfig: PoolConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		LogConfig: LogConfig{
+			Enabled:                   true,
+			Level:                     "warn",
+			IgnoreRecordNotFoundError: true,
+			ParameterizedQueries:      true,
+		},
+		SlowQueryConfig: SlowQueryConfig{
+			Enabled:   true,
+			Threshold: 200 * time.Millisecond,
+		},
+		MonitorConfig: MonitorConfig{
+			HealthCheckInterval: 30 * time.Second,
+			ConnectionTimeout:   5 * time.Second,
+			MaxRetries:          3,
+		},
+	}
+}
+
+// NewManagerWithOptions 使用函数式选项创建数据库管理器
+// 以DefaultConfig为基础依次应用选项，未通过WithType指定类型时根据DSN推断；
+// 最终配置与NewManager经过相同的校验
+// 参数:
+//   - dsn: 主库数据源名称
+//   - opts: 选项列表
+// 返回值:
+//   - Manager: 数据库管理器接口
+//   - error: 错误信息
+func NewManagerWithOptions(dsn string, opts ...Option) (Manager, error) {
+	o := &options{config: DefaultConfig()}
+	o.config.Master = dsn
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+
+	if o.config.Type == "" {
+		o.config.Type = detectDBType(dsn)
+	}
+
+	// 未设置连接池的从库沿用主库连接池配置
+	for i := range o.config.Slaves {
+		if o.config.Slaves[i].PoolConfig == (PoolConfig{}) {
+			o.config.Slaves[i].PoolConfig = o.config.PoolConfig
+		}
+	}
+
+	return NewManager(o.config, o.logger)
+}
+
+// WithType 指定数据库类型
+// 参数:
+//   - dbType: 数据库类型 (mysql, postgres, sqlite)
+// 返回值:
+//   - Option: 选项
+func WithType(dbType string) Option {
+	return func(o *options) {
+		o.config.Type = dbType
+	}
+}
+
+// WithReplica 添加从库，从库类型与主库相同
+// 参数:
+//   - dsn: 从库数据源名称
+//   - weight: 从库权重，读请求按权重比例分配，0按1处理
+// 返回值:
+//   - Option: 选项
+func WithReplica(dsn string, weight int) Option {
+	return func(o *options) {
+		o.config.Slaves = append(o.config.Slaves, SlaveConfig{DSN: dsn, Weight: weight})
+	}
+}
+
+// WithPool 设置主库连接池配置
+// 只覆盖非零的项，为0的项视为未设置并保留DefaultConfig的默认值；
+// 需要不限制连接数或生命周期时，修改DefaultConfig返回的配置后调用NewManager
+// 参数:
+//   - config: 连接池配置
+// 返回值:
+//   - Option: 选项
+func WithPool(config PoolConfig) Option {
+	return func(o *options) {
+		pool := &o.config.PoolConfig
+		if config.MaxOpenConns != 0 {
+			pool.MaxOpenConns = config.MaxOpenConns
+		}
+		if config.MaxIdleConns != 0 {
+			pool.MaxIdleConns = config.MaxIdleConns
+		}
+		if config.ConnMaxLifetime != 0 {
+			pool.ConnMaxLifetime = config.ConnMaxLifetime
+		}
+		if config.ConnMaxIdleTime != 0 {
+			pool.ConnMaxIdleTime = config.ConnMaxIdleTime
+		}
+	}
+}
+
+// WithSlowQuery 启用慢查询记录
+// 参数:
+//   - threshold: 慢查询阈值
+// 返回值:
+//   - Option: 选项
+func WithSlowQuery(threshold time.Duration) Option {
+	return func(o *options) {
+		o.config.SlowQueryConfig.Enabled = true
+		o.config.SlowQueryConfig.Threshold = threshold
+	}
+}
+
+// WithLogger 设置日志记录器
+// 参数:
+//   - logger: 日志记录器
+// 返回值:
+//   - Option: 选项
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithLogLevel 设置SQL日志级别
+// 参数:
+//   - level: 日志级别 (silent, error, warn, info)
+// 返回值:
+//   - Option: 选项
+func WithLogLevel(level string) Option {
+	return func(o *options) {
+		o.config.LogConfig.Enabled = true
+		o.config.LogConfig.Level = level
+	}
+}
+
+// WithMonitor 启用健康检查监控
+// 参数:
+//   - interval: 健康检查间隔
+//   - timeout: 连接超时时间
+// 返回值:
+//   - Option: 选项
+func WithMonitor(interval, timeout time.Duration) Option {
+	return func(o *options) {
+		o.config.MonitorConfig.Enabled = true
+		o.config.MonitorConfig.HealthCheckInterval = interval
+		o.config.MonitorConfig.ConnectionTimeout = timeout
+	}
+}
+
+// detectDBType 根据DSN格式推断数据库类型
+// 参数:
+//   - dsn: 数据源名称
+// 返回值:
+//   - string: 数据库类型，无法推断时返回空字符串
+func detectDBType(dsn string) string {
+	switch {
+	case dsn == "":
+		return ""
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return "postgres"
+	case strings.Contains(dsn, "@tcp("), strings.Contains(dsn, "@unix("):
+		return "mysql"
+	case strings.Contains(dsn, "host=") || strings.Contains(dsn, "dbname="):
+		return "postgres"
+	case dsn == ":memory:", strings.HasPrefix(dsn, "file:"),
+		strings.HasSuffix(dsn, ".db"), strings.HasSuffix(dsn, ".sqlite"), strings.HasSuffix(dsn, ".sqlite3"):
+		return "sqlite"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,89 @@
+// Package outbox 基于事务性发件箱模式的可靠事件发布
+// 业务修改与事件记录写入同一事务，由后台中继轮询未投递的事件并交给发布者，投递成功后标记为已投递。
+// 事件至少投递一次，消费者需要按消息ID做幂等处理
+package outbox
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Message 发件箱消息表模型
+type Message struct {
+	// ID 消息ID，按写入顺序递增
+	ID uint64 `gorm:"primaryKey"`
+	// Topic 消息主题
+	Topic string `gorm:"size:255;not null"`
+	// Key 消息键，如聚合根ID，可用于分区
+	Key string `gorm:"size:255"`
+	// Payload 消息内容
+	Payload []byte
+	// Attempts 已投递次数
+	Attempts int `gorm:"not null;default:0"`
+	// LastError 最近一次投递失败的错误信息
+	LastError string `gorm:"size:1024"`
+	// CreatedAt 写入时间
+	CreatedAt time.Time
+	// AvailableAt 可投递时间，投递失败后按退避时间推迟
+	AvailableAt time.Time `gorm:"not null;index"`
+	// DeliveredAt 投递成功时间，未投递时为nil
+	DeliveredAt *time.Time `gorm:"index"`
+}
+
+// TableName 返回表名
+// 返回值:
+//   - string: 表名
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Event 待发布的事件
+type Event struct {
+	// Topic 消息主题
+	Topic string
+	// Key 消息键
+	Key string
+	// Payload 消息内容
+	Payload []byte
+}
+
+// Migrate 创建或更新发件箱表
+// 参数:
+//   - db: 数据库实例
+// 返回值:
+//   - error: 错误信息
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Message{})
+}
+
+// Enqueue 在事务中写入待发布的事件
+// 必须在事务中调用，事件随业务修改一起提交或回滚
+// 参数:
+//   - tx: 事务实例，如Manager.Transaction的tx或事务中Manager.DB(ctx)的返回值
+//   - event: 事件
+// 返回值:
+//   - error: 错误信息
+func Enqueue(tx *gorm.DB, event Event) error {
+	if tx == nil {
+		return fmt.Errorf("outbox: transaction cannot be nil")
+	}
+	if committer, ok := tx.Statement.ConnPool.(gorm.TxCommitter); !ok || committer == nil {
+		return fmt.Errorf("outbox: Enqueue must be called inside a transaction")
+	}
+	if event.Topic == "" {
+		return fmt.Errorf("outbox: event topic cannot be empty")
+	}
+
+	msg := &Message{
+		Topic:       event.Topic,
+		Key:         event.Key,
+		Payload:     event.Payload,
+		AvailableAt: time.Now().UTC(),
+	}
+	if err := tx.Create(msg).Error; err != nil {
+		return fmt.Errorf("outbox: failed to enqueue event: %w", err)
+	}
+	return nil
+}
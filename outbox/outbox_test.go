@@ -0,0 +1,205 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// testOrder 测试业务模型
+type testOrder struct {
+	ID     uint `gorm:"primarykey"`
+	Amount int
+}
+
+// newTestManager 创建使用SQLite文件数据库的管理器
+func newTestManager(t *testing.T) database.Manager {
+	t.Helper()
+
+	config := database.DefaultConfig()
+	config.Type = "sqlite"
+	config.Master = filepath.Join(t.TempDir(), "app.db")
+	config.LogConfig.Level = "silent"
+	config.PoolConfig = database.PoolConfig{MaxOpenConns: 1}
+
+	manager, err := database.NewManager(config)
+	require.NoError(t, err)
+	require.NoError(t, Migrate(manager.GetDB()))
+	require.NoError(t, manager.GetDB().AutoMigrate(&testOrder{}))
+	return manager
+}
+
+// recordingPublisher 记录已发布消息的发布者
+type recordingPublisher struct {
+	mu       sync.Mutex
+	topics   []string
+	failures int
+}
+
+// Publish 发布消息，前failures次返回错误
+func (p *recordingPublisher) Publish(ctx context.Context, msg *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	p.topics = append(p.topics, msg.Topic)
+	return nil
+}
+
+// published 返回已发布的主题
+func (p *recordingPublisher) published() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.topics...)
+}
+
+func TestEnqueue(t *testing.T) {
+	manager := newTestManager(t)
+	defer manager.Close()
+	ctx := context.Background()
+
+	err := manager.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Create(&testOrder{Amount: 100}).Error; err != nil {
+			return err
+		}
+		return Enqueue(tx, Event{Topic: "order.created", Key: "1", Payload: []byte(`{"id":1}`)})
+	})
+	require.NoError(t, err)
+
+	// 回滚时事件一并撤销
+	err = manager.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := Enqueue(manager.DB(tx.Statement.Context), Event{Topic: "order.cancelled"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	var messages []Message
+	require.NoError(t, manager.GetDB().Find(&messages).Error)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "order.created", messages[0].Topic)
+	assert.Equal(t, "1", messages[0].Key)
+	assert.Equal(t, []byte(`{"id":1}`), messages[0].Payload)
+	assert.Nil(t, messages[0].DeliveredAt)
+
+	assert.ErrorContains(t, Enqueue(manager.GetDB(), Event{Topic: "order.created"}), "must be called inside a transaction")
+	assert.ErrorContains(t, manager.Transaction(ctx, func(tx *gorm.DB) error {
+		return Enqueue(tx, Event{})
+	}), "event topic cannot be empty")
+}
+
+func TestRelayRunOnce(t *testing.T) {
+	manager := newTestManager(t)
+	defer manager.Close()
+	ctx := context.Background()
+
+	enqueue := func(topics ...string) {
+		require.NoError(t, manager.Transaction(ctx, func(tx *gorm.DB) error {
+			for _, topic := range topics {
+				if err := Enqueue(tx, Event{Topic: topic}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}
+
+	publisher := &recordingPublisher{failures: 1}
+	relay, err := NewRelay(manager, publisher, RelayConfig{BatchSize: 10, MaxAttempts: 3, RetryBackoff: time.Hour})
+	require.NoError(t, err)
+
+	enqueue("a", "b")
+	delivered, err := relay.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{"b"}, publisher.published())
+
+	// 失败的消息在退避时间内不会被再次投递
+	delivered, err = relay.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+
+	var failed Message
+	require.NoError(t, manager.GetDB().Where("topic = ?", "a").First(&failed).Error)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, "broker unavailable", failed.LastError)
+	assert.Nil(t, failed.DeliveredAt)
+
+	// 退避时间到达后重新投递
+	require.NoError(t, manager.GetDB().Model(&failed).Update("available_at", time.Now().UTC().Add(-time.Second)).Error)
+	delivered, err = relay.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{"b", "a"}, publisher.published())
+
+	require.NoError(t, manager.GetDB().First(&failed, failed.ID).Error)
+	assert.Equal(t, 2, failed.Attempts)
+	assert.Empty(t, failed.LastError)
+	assert.NotNil(t, failed.DeliveredAt)
+
+	t.Run("超过最大投递次数", func(t *testing.T) {
+		publisher := &recordingPublisher{failures: 10}
+		relay, err := NewRelay(manager, publisher, RelayConfig{MaxAttempts: 2, RetryBackoff: time.Nanosecond})
+		require.NoError(t, err)
+
+		enqueue("c")
+		for i := 0; i < 3; i++ {
+			time.Sleep(time.Millisecond)
+			_, err := relay.RunOnce(ctx)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 8, publisher.failures)
+
+		var msg Message
+		require.NoError(t, manager.GetDB().Where("topic = ?", "c").First(&msg).Error)
+		assert.Equal(t, 2, msg.Attempts)
+		assert.Nil(t, msg.DeliveredAt)
+	})
+}
+
+func TestRelayLifecycle(t *testing.T) {
+	manager := newTestManager(t)
+	ctx := context.Background()
+
+	publisher := &recordingPublisher{}
+	relay, err := NewRelay(manager, publisher, RelayConfig{PollInterval: 10 * time.Millisecond, BatchSize: 2})
+	require.NoError(t, err)
+	require.NoError(t, relay.Start())
+
+	require.NoError(t, manager.Transaction(ctx, func(tx *gorm.DB) error {
+		for _, topic := range []string{"a", "b", "c"} {
+			if err := Enqueue(tx, Event{Topic: topic}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	assert.Eventually(t, func() bool {
+		return len(publisher.published()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"a", "b", "c"}, publisher.published())
+
+	// 关闭管理器时等待中继退出
+	require.NoError(t, manager.Close())
+	assert.ErrorContains(t, relay.Start(), "database manager is closed")
+
+	_, err = NewRelay(manager, nil, RelayConfig{})
+	assert.Error(t, err)
+
+	// 只实现Manager接口的管理器无法运行后台中继
+	wrapped := struct{ database.Manager }{manager}
+	_, err = NewRelay(wrapped, publisher, RelayConfig{})
+	assert.ErrorContains(t, err, "does not support background tasks")
+}
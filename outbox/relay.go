@@ -0,0 +1,281 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 中继的默认配置
+const (
+	// defaultPollInterval 默认轮询间隔
+	defaultPollInterval = time.Second
+	// defaultBatchSize 默认每批处理的消息数
+	defaultBatchSize = 100
+	// defaultMaxAttempts 默认最大投递次数
+	defaultMaxAttempts = 10
+	// defaultRetryBackoff 默认首次重试等待时间
+	defaultRetryBackoff = time.Second
+	// defaultMaxRetryBackoff 默认重试等待时间上限
+	defaultMaxRetryBackoff = 5 * time.Minute
+	// maxLastErrorLength LastError字段的最大长度
+	maxLastErrorLength = 1024
+)
+
+// Publisher 消息发布者接口
+type Publisher interface {
+	// Publish 发布消息，返回错误时消息在退避后重新投递
+	Publish(ctx context.Context, msg *Message) error
+}
+
+// PublisherFunc 函数形式的消息发布者
+type PublisherFunc func(ctx context.Context, msg *Message) error
+
+// Publish 发布消息
+// 参数:
+//   - ctx: 上下文
+//   - msg: 消息
+// 返回值:
+//   - error: 错误信息
+func (f PublisherFunc) Publish(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// RelayConfig 中继配置结构体
+type RelayConfig struct {
+	// 轮询间隔，默认1秒
+	PollInterval time.Duration `json:"poll_interval" yaml:"poll_interval" mapstructure:"poll_interval"`
+	// 每批处理的消息数，默认100；一批处理满时立即处理下一批
+	BatchSize int `json:"batch_size" yaml:"batch_size" mapstructure:"batch_size"`
+	// 最大投递次数，达到后不再投递，需人工处理，默认10
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts" mapstructure:"max_attempts"`
+	// 投递失败后首次重试的等待时间，之后每次翻倍，默认1秒
+	RetryBackoff time.Duration `json:"retry_backoff" yaml:"retry_backoff" mapstructure:"retry_backoff"`
+	// 重试等待时间上限，默认5分钟
+	MaxRetryBackoff time.Duration `json:"max_retry_backoff" yaml:"max_retry_backoff" mapstructure:"max_retry_backoff"`
+	// 禁用FOR UPDATE SKIP LOCKED，用于不支持该语法的数据库版本（如MySQL 5.7）
+	DisableSkipLocked bool `json:"disable_skip_locked" yaml:"disable_skip_locked" mapstructure:"disable_skip_locked"`
+}
+
+// runner 在管理器生命周期内运行后台任务并提供日志记录器，*database.DBManager实现了该接口
+type runner interface {
+	Go(fn func(ctx context.Context)) error
+	Logger() database.Logger
+}
+
+// Relay 发件箱中继
+// 轮询未投递的消息并交给发布者。MySQL 8.0+和PostgreSQL上使用FOR UPDATE SKIP LOCKED，多个实例可并发运行
+type Relay struct {
+	// manager 数据库管理器
+	manager database.Manager
+	// runner 运行后台中继并记录日志，即manager本身
+	runner runner
+	// publisher 消息发布者
+	publisher Publisher
+	// config 中继配置
+	config RelayConfig
+}
+
+// NewRelay 创建发件箱中继
+// 参数:
+//   - manager: 数据库管理器，需实现Go和Logger方法，如NewManager返回的管理器
+//   - publisher: 消息发布者
+//   - config: 中继配置，零值字段使用默认值
+// 返回值:
+//   - *Relay: 发件箱中继
+//   - error: 错误信息
+func NewRelay(manager database.Manager, publisher Publisher, config RelayConfig) (*Relay, error) {
+	if manager == nil {
+		return nil, fmt.Errorf("outbox: manager cannot be nil")
+	}
+	runner, ok := manager.(runner)
+	if !ok {
+		return nil, fmt.Errorf("outbox: manager %T does not support background tasks", manager)
+	}
+	if publisher == nil {
+		return nil, fmt.Errorf("outbox: publisher cannot be nil")
+	}
+
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+	if config.MaxRetryBackoff <= 0 {
+		config.MaxRetryBackoff = defaultMaxRetryBackoff
+	}
+
+	return &Relay{manager: manager, runner: runner, publisher: publisher, config: config}, nil
+}
+
+// Start 在管理器生命周期内启动后台中继
+// 管理器关闭时中继停止，Close会等待当前批次处理完成
+// 返回值:
+//   - error: 管理器已关闭时返回错误
+func (r *Relay) Start() error {
+	return r.runner.Go(r.run)
+}
+
+// RunOnce 处理一批待投递的消息
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - int: 投递成功的消息数
+//   - error: 数据库错误，发布失败不作为错误返回
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	_, delivered, err := r.runBatch(ctx)
+	return delivered, err
+}
+
+// run 后台轮询循环
+// 参数:
+//   - ctx: 管理器上下文
+func (r *Relay) run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		fetched, _, err := r.runBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.runner.Logger().Error(ctx, "outbox relay failed: %v", err)
+		}
+
+		// 一批处理满时可能还有积压，立即处理下一批
+		if err == nil && fetched == r.config.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runBatch 在一个事务中锁定并投递一批消息
+// 消息在投递期间保持锁定，投递结果随事务提交；提交失败时已发布的消息会被再次投递
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - int: 取出的消息数
+//   - int: 投递成功的消息数
+//   - error: 错误信息
+func (r *Relay) runBatch(ctx context.Context) (int, int, error) {
+	var fetched, delivered int
+	err := r.manager.Transaction(ctx, func(tx *gorm.DB) error {
+		fetched, delivered = 0, 0
+
+		query := tx.Where("delivered_at IS NULL AND attempts < ? AND available_at <= ?", r.config.MaxAttempts, time.Now().UTC()).
+			Order("id").
+			Limit(r.config.BatchSize)
+		if r.skipLocked(tx) {
+			query = query.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked})
+		}
+
+		var messages []Message
+		if err := query.Find(&messages).Error; err != nil {
+			return fmt.Errorf("failed to fetch outbox messages: %w", err)
+		}
+		fetched = len(messages)
+
+		for i := range messages {
+			msg := &messages[i]
+			updates := r.deliver(ctx, msg)
+			if msg.DeliveredAt != nil {
+				delivered++
+			}
+			if err := tx.Model(msg).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update outbox message %d: %w", msg.ID, err)
+			}
+		}
+		return nil
+	})
+	return fetched, delivered, err
+}
+
+// deliver 投递单条消息并返回需要更新的字段
+// 参数:
+//   - ctx: 上下文
+//   - msg: 消息
+// 返回值:
+//   - map[string]interface{}: 需要更新的字段
+func (r *Relay) deliver(ctx context.Context, msg *Message) map[string]interface{} {
+	msg.Attempts++
+	now := time.Now().UTC()
+
+	err := r.publisher.Publish(ctx, msg)
+	if err == nil {
+		msg.DeliveredAt = &now
+		return map[string]interface{}{
+			"attempts":     msg.Attempts,
+			"last_error":   "",
+			"delivered_at": now,
+		}
+	}
+
+	lastError := err.Error()
+	if len(lastError) > maxLastErrorLength {
+		lastError = strings.ToValidUTF8(lastError[:maxLastErrorLength], "")
+	}
+	if msg.Attempts >= r.config.MaxAttempts {
+		r.runner.Logger().Error(ctx, "outbox message %d (%s) gave up after %d attempts: %v", msg.ID, msg.Topic, msg.Attempts, err)
+	} else {
+		r.runner.Logger().Warn(ctx, "outbox message %d (%s) delivery failed on attempt %d: %v", msg.ID, msg.Topic, msg.Attempts, err)
+	}
+
+	return map[string]interface{}{
+		"attempts":     msg.Attempts,
+		"last_error":   lastError,
+		"available_at": now.Add(r.retryBackoff(msg.Attempts)),
+	}
+}
+
+// retryBackoff 计算第attempts次投递失败后的等待时间
+// 参数:
+//   - attempts: 已投递次数
+// 返回值:
+//   - time.Duration: 等待时间
+func (r *Relay) retryBackoff(attempts int) time.Duration {
+	backoff := r.config.RetryBackoff
+	for i := 1; i < attempts && backoff < r.config.MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.config.MaxRetryBackoff {
+		backoff = r.config.MaxRetryBackoff
+	}
+	return backoff
+}
+
+// skipLocked 判断是否使用FOR UPDATE SKIP LOCKED
+// 参数:
+//   - tx: 事务实例
+// 返回值:
+//   - bool: 是否使用
+func (r *Relay) skipLocked(tx *gorm.DB) bool {
+	if r.config.DisableSkipLocked {
+		return false
+	}
+	switch tx.Dialector.Name() {
+	case "mysql", "postgres":
+		return true
+	default:
+		return false
+	}
+}
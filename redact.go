@@ -0,0 +1,536 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// defaultRedactPlaceholder 默认脱敏占位符
+	defaultRedactPlaceholder = "[REDACTED]"
+	// sensitiveTagSetting 标记敏感字段的GORM标签，如 `gorm:"column:password;sensitive"`
+	sensitiveTagSetting = "SENSITIVE"
+)
+
+// redactor SQL日志脱敏器
+// 按列名规则、GORM标签和正则表达式替换日志中的敏感值
+type redactor struct {
+	// placeholder 脱敏占位符
+	placeholder string
+	// columns 列名匹配规则（小写）
+	columns []string
+	// patterns 作用于SQL文本的正则表达式
+	patterns []*regexp.Regexp
+	// taggedColumns 从模型标签中收集到的敏感列
+	taggedColumns sync.Map
+	// parsedSchemas 已处理过的模型
+	parsedSchemas sync.Map
+}
+
+// newRedactor 根据配置创建脱敏器
+// 参数:
+//   - config: 脱敏配置
+// 返回值:
+//   - *redactor: 脱敏器，未启用时返回nil
+//   - error: 正则表达式编译错误
+func newRedactor(config RedactConfig) (*redactor, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	r := &redactor{placeholder: config.Placeholder}
+	if r.placeholder == "" {
+		r.placeholder = defaultRedactPlaceholder
+	}
+
+	for _, column := range config.Columns {
+		if column = strings.ToLower(strings.TrimSpace(column)); column != "" {
+			r.columns = append(r.columns, column)
+		}
+	}
+
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// isSensitiveColumn 判断列是否需要脱敏
+// 参数:
+//   - column: 列名
+// 返回值:
+//   - bool: 是否敏感
+func (r *redactor) isSensitiveColumn(column string) bool {
+	if column == "" {
+		return false
+	}
+
+	column = strings.ToLower(column)
+	if _, ok := r.taggedColumns.Load(column); ok {
+		return true
+	}
+
+	for _, rule := range r.columns {
+		if strings.Contains(column, rule) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactVars 替换敏感列对应的绑定参数
+// 参数:
+//   - sql: 带占位符的SQL语句
+//   - vars: 绑定参数
+// 返回值:
+//   - []interface{}: 脱敏后的参数副本
+func (r *redactor) redactVars(sql string, vars []interface{}) []interface{} {
+	if len(vars) == 0 {
+		return vars
+	}
+
+	var redacted []interface{}
+	for i, column := range placeholderColumns(sql, len(vars)) {
+		if !r.isSensitiveColumn(column) {
+			continue
+		}
+		if redacted == nil {
+			redacted = make([]interface{}, len(vars))
+			copy(redacted, vars)
+		}
+		redacted[i] = r.placeholder
+	}
+
+	if redacted == nil {
+		return vars
+	}
+	return redacted
+}
+
+// redactSQL 对最终SQL文本应用正则规则
+// 正则包含捕获组时只替换捕获组内容，否则替换整个匹配
+// 参数:
+//   - sql: SQL语句
+// 返回值:
+//   - string: 脱敏后的SQL语句
+func (r *redactor) redactSQL(sql string) string {
+	for _, re := range r.patterns {
+		if re.NumSubexp() == 0 {
+			sql = re.ReplaceAllLiteralString(sql, r.placeholder)
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, loc := range re.FindAllStringSubmatchIndex(sql, -1) {
+			for g := 1; g <= re.NumSubexp(); g++ {
+				start, end := loc[2*g], loc[2*g+1]
+				if start < last || start < 0 {
+					continue
+				}
+				b.WriteString(sql[last:start])
+				b.WriteString(r.placeholder)
+				last = end
+			}
+		}
+		b.WriteString(sql[last:])
+		sql = b.String()
+	}
+	return sql
+}
+
+// errorValuePattern 数据库错误信息中引用列或索引的值，如MySQL的 "Duplicate entry 'x' for key 'users.email'"
+var errorValuePattern = regexp.MustCompile(`'(.*?)' for (?:key|column) '([^']*)'`)
+
+// redactedError 脱敏后的错误
+// 错误信息中的敏感值已被替换，errors.Is和errors.As仍可匹配原始错误
+type redactedError struct {
+	// msg 脱敏后的错误信息
+	msg string
+	// err 原始错误
+	err error
+}
+
+// Error 返回脱敏后的错误信息
+// 返回值:
+//   - string: 错误信息
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// Unwrap 返回原始错误
+// 返回值:
+//   - error: 原始错误
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError 替换错误信息中的敏感值
+// 错误信息引用的列或索引涉及敏感列时替换其中的值，之后与SQL文本一样应用正则规则
+// 参数:
+//   - err: 执行错误
+// 返回值:
+//   - error: 脱敏后的错误，无需替换时返回原错误
+func (r *redactor) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	text := err.Error()
+	var b strings.Builder
+	last := 0
+	for _, loc := range errorValuePattern.FindAllStringSubmatchIndex(text, -1) {
+		if !r.mentionsSensitiveColumn(text[loc[4]:loc[5]]) {
+			continue
+		}
+		b.WriteString(text[last:loc[2]])
+		b.WriteString(r.placeholder)
+		last = loc[3]
+	}
+	b.WriteString(text[last:])
+
+	msg := r.redactSQL(b.String())
+	if msg == text {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+// mentionsSensitiveColumn 判断错误信息中的列名或索引名是否涉及敏感列
+// 索引名通常由表名和列名组成，如 users.idx_users_email，因此按包含关系匹配
+// 参数:
+//   - name: 列名或索引名
+// 返回值:
+//   - bool: 是否涉及敏感列
+func (r *redactor) mentionsSensitiveColumn(name string) bool {
+	name = strings.ToLower(name)
+	if r.isSensitiveColumn(name) {
+		return true
+	}
+
+	found := false
+	r.taggedColumns.Range(func(column, _ interface{}) bool {
+		found = strings.Contains(name, column.(string))
+		return !found
+	})
+	return found
+}
+
+// learnSchema 收集模型中带sensitive标签的列
+// 参数:
+//   - s: 模型结构
+func (r *redactor) learnSchema(s *schema.Schema) {
+	if s == nil {
+		return
+	}
+	if _, loaded := r.parsedSchemas.LoadOrStore(s, struct{}{}); loaded {
+		return
+	}
+
+	for _, field := range s.Fields {
+		if _, ok := field.TagSettings[sensitiveTagSetting]; ok && field.DBName != "" {
+			r.taggedColumns.Store(strings.ToLower(field.DBName), struct{}{})
+		}
+	}
+}
+
+// registerRedactCallbacks 注册收集敏感列的回调
+// 回调在SQL执行前运行，保证记录日志时已知晓模型上的敏感标签；
+// 每次执行时通过current获取当前脱敏器，因此重载配置后无需重新注册
+// 参数:
+//   - db: 数据库实例
+//   - current: 返回当前脱敏器的函数，未启用脱敏时返回nil
+// 返回值:
+//   - error: 注册错误
+func registerRedactCallbacks(db *gorm.DB, current func() *redactor) error {
+	const name = "database:redact_schema"
+	learn := func(tx *gorm.DB) {
+		if r := current(); r != nil {
+			r.learnSchema(tx.Statement.Schema)
+		}
+	}
+
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("*").Register(name, learn); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("*").Register(name, learn); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("*").Register(name, learn); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("*").Register(name, learn); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("*").Register(name, learn); err != nil {
+		return err
+	}
+	return callbacks.Raw().Before("*").Register(name, learn)
+}
+
+// sqlToken SQL词法单元
+type sqlToken struct {
+	// kind 类型: ident, placeholder, punct
+	kind string
+	// text 文本（标识符已去除引号）
+	text string
+	// index 占位符对应的参数下标
+	index int
+}
+
+// tokenizeSQL 将SQL切分为脱敏所需的简单词法单元
+// 字符串字面量和注释被跳过，?与$N均识别为占位符
+// 参数:
+//   - sql: SQL语句
+// 返回值:
+//   - []sqlToken: 词法单元
+func tokenizeSQL(sql string) []sqlToken {
+	var tokens []sqlToken
+	next := 0
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'':
+			// 跳过字符串字面量，''为转义
+			i++
+			for i < len(sql) {
+				if sql[i] == '\\' {
+					i += 2
+					continue
+				}
+				if sql[i] == '\'' {
+					if i+1 < len(sql) && sql[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case c == '`' || c == '"':
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				return tokens
+			}
+			tokens = append(tokens, sqlToken{kind: "ident", text: sql[i+1 : i+1+end]})
+			i += end + 2
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+		case c == '?':
+			tokens = append(tokens, sqlToken{kind: "placeholder", index: next})
+			next++
+			i++
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			j := i + 1
+			n := 0
+			for j < len(sql) && isDigit(sql[j]) {
+				n = n*10 + int(sql[j]-'0')
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: "placeholder", index: n - 1})
+			i = j
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(sql) && (isIdentStart(sql[j]) || isDigit(sql[j]) || sql[j] == '$') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: "ident", text: sql[i:j]})
+			i = j
+		case isDigit(c):
+			j := i + 1
+			for j < len(sql) && (isDigit(sql[j]) || sql[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: "number", text: sql[i:j]})
+			i = j
+		case c == '<' || c == '>' || c == '=' || c == '!':
+			j := i + 1
+			for j < len(sql) && (sql[j] == '<' || sql[j] == '>' || sql[j] == '=') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: "operator", text: sql[i:j]})
+			i = j
+		default:
+			tokens = append(tokens, sqlToken{kind: "punct", text: string(c)})
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// placeholderColumns 推断每个绑定参数对应的列名
+// 支持 INSERT 列列表、col = ?、col IN (?, ?)、col LIKE ? 等常见形式，无法推断时为空字符串
+// 参数:
+//   - sql: 带占位符的SQL语句
+//   - count: 绑定参数个数
+// 返回值:
+//   - []string: 与参数下标对应的列名
+func placeholderColumns(sql string, count int) []string {
+	columns := make([]string, count)
+	tokens := tokenizeSQL(sql)
+
+	assign := func(index int, column string) {
+		if index >= 0 && index < count && columns[index] == "" {
+			columns[index] = column
+		}
+	}
+
+	insertColumns := parseInsertColumns(tokens)
+
+	for i, tok := range tokens {
+		if tok.kind != "placeholder" {
+			continue
+		}
+
+		if column := insertColumns[i]; column != "" {
+			assign(tok.index, column)
+			continue
+		}
+
+		assign(tok.index, comparedColumn(tokens, i))
+	}
+
+	return columns
+}
+
+// parseInsertColumns 解析INSERT语句中VALUES元组与列的对应关系
+// 参数:
+//   - tokens: 词法单元
+// 返回值:
+//   - map[int]string: 占位符词法单元下标到列名的映射
+func parseInsertColumns(tokens []sqlToken) map[int]string {
+	result := make(map[int]string)
+	if len(tokens) == 0 || !strings.EqualFold(tokens[0].text, "INSERT") {
+		return result
+	}
+
+	// 定位列列表
+	i := 0
+	for i < len(tokens) && tokens[i].text != "(" {
+		i++
+	}
+	var columns []string
+	for i++; i < len(tokens) && tokens[i].text != ")"; i++ {
+		if tokens[i].kind == "ident" {
+			columns = append(columns, tokens[i].text)
+		}
+	}
+
+	// 定位VALUES
+	for i < len(tokens) && !strings.EqualFold(tokens[i].text, "VALUES") {
+		i++
+	}
+
+	depth, position := 0, 0
+	for i++; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.text == "(":
+			depth++
+			if depth == 1 {
+				position = 0
+			}
+		case tok.text == ")":
+			depth--
+			if depth < 0 {
+				return result
+			}
+		case tok.text == "," && depth == 1:
+			position++
+		case tok.kind == "placeholder" && depth >= 1:
+			if position < len(columns) {
+				result[i] = columns[position]
+			}
+		case depth == 0 && tok.kind == "ident":
+			// VALUES 之后的 ON CONFLICT / ON DUPLICATE KEY 等交给比较规则处理
+			return result
+		}
+	}
+
+	return result
+}
+
+// comparedColumn 查找与占位符比较或赋值的列名
+// 参数:
+//   - tokens: 词法单元
+//   - i: 占位符所在下标
+// 返回值:
+//   - string: 列名
+func comparedColumn(tokens []sqlToken, i int) string {
+	j := i - 1
+
+	// IN (?, ?, ?) 回溯到左括号
+	if j >= 0 && (tokens[j].text == "," || tokens[j].text == "(") {
+		depth := 0
+		for ; j >= 0; j-- {
+			if tokens[j].text == ")" {
+				depth++
+			} else if tokens[j].text == "(" {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		j--
+		if j < 0 || !strings.EqualFold(tokens[j].text, "IN") {
+			return ""
+		}
+		j--
+		if j >= 0 && strings.EqualFold(tokens[j].text, "NOT") {
+			j--
+		}
+	} else if j >= 0 && (tokens[j].kind == "operator" || isComparisonKeyword(tokens[j].text)) {
+		j--
+		if j >= 0 && strings.EqualFold(tokens[j].text, "NOT") {
+			j--
+		}
+	} else {
+		return ""
+	}
+
+	if j >= 0 && tokens[j].kind == "ident" && !isComparisonKeyword(tokens[j].text) {
+		return tokens[j].text
+	}
+	return ""
+}
+
+// isComparisonKeyword 判断是否为比较关键字
+func isComparisonKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "LIKE", "ILIKE", "IS", "REGEXP":
+		return true
+	}
+	return false
+}
+
+// isIdentStart 判断是否为标识符起始字符
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// isDigit 判断是否为数字
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
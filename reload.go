@@ -0,0 +1,278 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ErrRestartRequired 配置变更无法在运行时生效，需要重启服务
+var ErrRestartRequired = errors.New("config change requires restart")
+
+// defaultWatchInterval 配置文件监视的默认轮询间隔
+const defaultWatchInterval = 5 * time.Second
+
+// replicaDrainDelay 重建主从分离后旧从库连接池的保留时间
+// 仍持有旧GORM实例的调用方在此期间可以继续读取
+var replicaDrainDelay = 30 * time.Second
+
+// replicaDrainPollInterval 等待旧从库连接池空闲的轮询间隔
+const replicaDrainPollInterval = 100 * time.Millisecond
+
+// Reload 重新加载配置
+// 连接池、日志、慢查询和监控配置原地生效；从库列表变化时复用主库连接池重建主从分离并替换GORM实例，
+// 旧实例的从库连接池保留replicaDrainDelay并在进行中的查询完成后关闭，因此应通过GetDB等方法按需获取实例而不是长期持有。
+// 主库类型、DSN、结构化连接、凭据文件/环境变量、初始化语句和TLS配置的变更需要重启，此时返回包含ErrRestartRequired的ConfigErrors，不应用任何变更
+// 参数:
+//   - config: 新配置
+// 返回值:
+//   - error: 错误信息，管理器已关闭时返回错误
+func (m *DBManager) Reload(config *Config) error {
+	if config == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+	if err := validateConfig(config); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	m.mu.RLock()
+	old, db, slowQueryLogger, closed := m.config, m.db, m.slowQueryLogger, m.closed
+	m.mu.RUnlock()
+	if closed {
+		return fmt.Errorf("database manager is closed")
+	}
+
+	if err := checkRestartRequired(old, config); err != nil {
+		return err
+	}
+
+	var (
+		slowQueryChanged = !reflect.DeepEqual(old.SlowQueryConfig, config.SlowQueryConfig)
+		loggingChanged   = slowQueryChanged || !reflect.DeepEqual(old.LogConfig, config.LogConfig)
+		// 自定义凭据提供者无法比较，包含Provider的从库配置总会触发重建
+		slavesChanged  = !reflect.DeepEqual(old.Slaves, config.Slaves)
+		poolChanged    = old.PoolConfig != config.PoolConfig
+		monitorChanged = old.MonitorConfig != config.MonitorConfig
+	)
+
+	// 先准备好所有新资源，失败时保持当前配置不变
+	var gormLogger logger.Interface
+	if loggingChanged {
+		r, err := newRedactor(config.LogConfig.Redact)
+		if err != nil {
+			return err
+		}
+		if slowQueryChanged {
+			if slowQueryLogger, err = newSlowQueryLogger(config.SlowQueryConfig, m.logger, config.Type); err != nil {
+				return fmt.Errorf("failed to create slow query logger: %w", err)
+			}
+		}
+		gormLogger = m.createGormLogger(config, r, slowQueryLogger)
+	}
+
+	var (
+		newDB        *gorm.DB
+		replicaPools []*sql.DB
+	)
+	if slavesChanged {
+		var err error
+		if newDB, replicaPools, err = m.openDB(config, db.ConnPool); err != nil {
+			if slowQueryChanged {
+				slowQueryLogger.Close()
+			}
+			return fmt.Errorf("failed to rebuild db resolver: %w", err)
+		}
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+
+	m.mu.Lock()
+	if poolChanged {
+		applyPoolConfig(sqlDB, config.PoolConfig)
+	}
+	if gormLogger != nil {
+		m.gormLogger.store(gormLogger)
+	}
+	oldSlowQueryLogger := m.slowQueryLogger
+	m.slowQueryLogger = slowQueryLogger
+	oldReplicaPools := m.replicaPools
+	if slavesChanged {
+		m.db = newDB
+		m.replicaPools = replicaPools
+	}
+	if monitorChanged {
+		m.stopMonitoring()
+		if config.MonitorConfig.Enabled && !m.closed {
+			m.startMonitoring(config.MonitorConfig)
+		}
+	}
+	m.config = config
+	m.mu.Unlock()
+
+	// 替换完成后释放旧资源
+	if slowQueryChanged {
+		if err := oldSlowQueryLogger.Close(); err != nil {
+			m.logger.Error(m.ctx, "failed to close slow query log: %v", err)
+		}
+	}
+	if slavesChanged {
+		m.retireReplicaPools(oldReplicaPools)
+	}
+
+	m.logger.Info(m.ctx, "database config reloaded")
+	return nil
+}
+
+// WatchConfig 监视配置文件并在变化时自动重载
+// 按interval轮询文件的修改时间和大小，变化后通过LoadConfig加载并调用Reload；
+// 加载或重载失败时记录错误日志并保持当前配置。ctx取消或管理器关闭时停止监视
+// 参数:
+//   - ctx: 上下文
+//   - path: 配置文件路径
+//   - interval: 轮询间隔，小于等于0时为5秒
+//   - envPrefix: 环境变量前缀，可选，与LoadConfig相同
+// 返回值:
+//   - error: 配置文件不可访问或管理器已关闭时返回错误
+func (m *DBManager) WatchConfig(ctx context.Context, path string, interval time.Duration, envPrefix ...string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	return m.Go(func(managerCtx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		modTime, size := info.ModTime(), info.Size()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-managerCtx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil {
+					m.logger.Error(m.ctx, "failed to stat config file %s: %v", path, err)
+					continue
+				}
+				if info.ModTime().Equal(modTime) && info.Size() == size {
+					continue
+				}
+				modTime, size = info.ModTime(), info.Size()
+
+				config, err := LoadConfig(path, envPrefix...)
+				if err != nil {
+					m.logger.Error(m.ctx, "failed to load config file %s: %v", path, err)
+					continue
+				}
+				if err := m.Reload(config); err != nil {
+					m.logger.Error(m.ctx, "failed to reload config file %s: %v", path, err)
+				}
+			}
+		}
+	})
+}
+
+// retireReplicaPools 延迟关闭被替换的从库连接池
+// 保留replicaDrainDelay后等待进行中的查询完成再关闭；管理器关闭时立即关闭
+// 参数:
+//   - pools: 被替换的从库连接池
+func (m *DBManager) retireReplicaPools(pools []*sql.DB) {
+	if len(pools) == 0 {
+		return
+	}
+
+	err := m.Go(func(ctx context.Context) {
+		defer closeDBs(pools)
+
+		timer := time.NewTimer(replicaDrainDelay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		ticker := time.NewTicker(replicaDrainPollInterval)
+		defer ticker.Stop()
+		for !poolsIdle(pools) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+	if err != nil {
+		closeDBs(pools)
+	}
+}
+
+// poolsIdle 检查连接池是否都没有使用中的连接
+// 参数:
+//   - pools: 连接池
+// 返回值:
+//   - bool: 是否都空闲
+func poolsIdle(pools []*sql.DB) bool {
+	for _, pool := range pools {
+		if pool.Stats().InUse > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRestartRequired 检查无法在运行时生效的配置变更
+// 参数:
+//   - old: 当前配置
+//   - config: 新配置
+// 返回值:
+//   - error: 需要重启时返回ConfigErrors
+func checkRestartRequired(old, config *Config) error {
+	var errs ConfigErrors
+	restart := func(key string) {
+		errs = append(errs, &ConfigError{Key: key, Err: ErrRestartRequired})
+	}
+
+	if normalizeDBType(old.Type) != normalizeDBType(config.Type) {
+		restart("type")
+	}
+	if old.Master != config.Master {
+		restart("master")
+	}
+	if !reflect.DeepEqual(old.Connection, config.Connection) {
+		restart("connection")
+	}
+	if old.Credentials.PasswordFile != config.Credentials.PasswordFile ||
+		old.Credentials.PasswordEnv != config.Credentials.PasswordEnv {
+		restart("credentials")
+	}
+	if !reflect.DeepEqual(old.InitStatements, config.InitStatements) {
+		restart("init_statements")
+	}
+	if old.TLS != config.TLS {
+		restart("tls")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotateTimeFormat 轮转文件名中的时间格式
+const rotateTimeFormat = "20060102T150405.000"
+
+// rotatingFile 按大小和时间轮转的日志文件
+// 轮转后的文件命名为 name-<时间>.ext，与当前文件位于同一目录
+type rotatingFile struct {
+	// path 当前日志文件路径
+	path string
+	// maxSize 单个文件最大字节数，0表示不限制
+	maxSize int64
+	// maxAge 单个文件最长写入时间，0表示不限制
+	maxAge time.Duration
+	// maxBackups 保留的轮转文件数量，0表示全部保留
+	maxBackups int
+
+	// mu 互斥锁，保护文件写入与轮转
+	mu sync.Mutex
+	// file 当前文件
+	file *os.File
+	// size 当前文件大小
+	size int64
+	// openedAt 当前文件打开时间
+	openedAt time.Time
+}
+
+// newRotatingFile 打开按大小和时间轮转的日志文件
+// 参数:
+//   - path: 日志文件路径
+//   - maxSizeMB: 单个文件最大大小（MB）
+//   - maxAge: 单个文件最长写入时间
+//   - maxBackups: 保留的轮转文件数量
+// 返回值:
+//   - *rotatingFile: 轮转文件
+//   - error: 错误信息
+func newRotatingFile(path string, maxSizeMB int, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write 写入日志，必要时先轮转
+// 参数:
+//   - p: 日志内容
+// 返回值:
+//   - int: 写入字节数
+//   - error: 错误信息
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close 关闭日志文件
+// 返回值:
+//   - error: 错误信息
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// shouldRotate 判断写入前是否需要轮转
+func (r *rotatingFile) shouldRotate(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.maxSize > 0 && r.size+n > r.maxSize {
+		return true
+	}
+	return r.maxAge > 0 && time.Since(r.openedAt) >= r.maxAge
+}
+
+// open 打开当前日志文件（追加模式）
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %w", r.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file %s: %w", r.path, err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = time.Now()
+	return nil
+}
+
+// rotate 将当前文件重命名为轮转文件并重新打开
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file %s: %w", r.path, err)
+	}
+	r.file = nil
+
+	ext := filepath.Ext(r.path)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, ext), time.Now().Format(rotateTimeFormat), ext)
+	if err := os.Rename(r.path, backup); err != nil {
+		// 重命名失败时继续追加写入原文件
+		err = fmt.Errorf("failed to rotate log file %s: %w", r.path, err)
+		if openErr := r.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.removeOldBackups()
+	return nil
+}
+
+// removeOldBackups 删除超出保留数量的轮转文件
+func (r *rotatingFile) removeOldBackups() {
+	if r.maxBackups <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return
+	}
+	var backups []string
+	for _, entry := range entries {
+		if !entry.IsDir() && r.isBackup(entry.Name()) {
+			backups = append(backups, filepath.Join(filepath.Dir(r.path), entry.Name()))
+		}
+	}
+	if len(backups) <= r.maxBackups {
+		return
+	}
+
+	// 文件名中的时间戳保证字典序即时间顺序
+	sort.Strings(backups)
+	for _, backup := range backups[:len(backups)-r.maxBackups] {
+		os.Remove(backup)
+	}
+}
+
+// isBackup 判断文件名是否为当前文件的轮转文件
+// 只匹配 name-<时间>.ext 且时间符合rotateTimeFormat的文件，避免误删同名前缀的其他文件
+// 参数:
+//   - name: 文件名
+// 返回值:
+//   - bool: 是否为轮转文件
+func (r *rotatingFile) isBackup(name string) bool {
+	base := filepath.Base(r.path)
+	ext := filepath.Ext(base)
+	stamp, ok := strings.CutPrefix(name, strings.TrimSuffix(base, ext)+"-")
+	if !ok {
+		return false
+	}
+	if stamp, ok = strings.CutSuffix(stamp, ext); !ok || len(stamp) != len(rotateTimeFormat) {
+		return false
+	}
+	_, err := time.Parse(rotateTimeFormat, stamp)
+	return err == nil
+}
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// samplerBuckets 采样计数桶数量，按SQL指纹哈希分桶以限制内存占用
+	samplerBuckets = 4096
+	// defaultSampleInterval 默认采样周期
+	defaultSampleInterval = time.Second
+	// defaultSampleSlowThreshold 未配置慢查询阈值时采用的默认值
+	defaultSampleSlowThreshold = defaultSlowThreshold
+)
+
+// sampleCounter 单个指纹桶在当前周期内的计数
+type sampleCounter struct {
+	// resetAt 本周期结束时间（UnixNano）
+	resetAt atomic.Int64
+	// count 本周期内的计数
+	count atomic.Uint64
+}
+
+// incCheckReset 计数加一，周期结束时重新计数
+// 参数:
+//   - now: 当前时间（UnixNano）
+//   - interval: 采样周期
+// 返回值:
+//   - uint64: 本周期内的计数
+func (c *sampleCounter) incCheckReset(now int64, interval time.Duration) uint64 {
+	resetAfter := c.resetAt.Load()
+	if resetAfter > now {
+		return c.count.Add(1)
+	}
+
+	c.count.Store(1)
+	newResetAfter := now + interval.Nanoseconds()
+	if !c.resetAt.CompareAndSwap(resetAfter, newResetAfter) {
+		// 其他协程已完成重置
+		return c.count.Add(1)
+	}
+	return 1
+}
+
+// sampler SQL日志采样器
+// 每个周期内每种SQL指纹先记录Initial条，之后每Thereafter条记录1条；错误和慢查询始终记录
+type sampler struct {
+	// initial 每周期先记录的条数
+	initial uint64
+	// thereafter 超出后的采样间隔
+	thereafter uint64
+	// interval 采样周期
+	interval time.Duration
+	// slowThreshold 慢查询阈值，超过此值的SQL不参与采样
+	slowThreshold time.Duration
+	// dbType 数据库类型，用于计算SQL指纹
+	dbType string
+	// counters 计数桶
+	counters [samplerBuckets]sampleCounter
+}
+
+// newSampler 根据配置创建采样器
+// 参数:
+//   - config: 采样配置
+//   - slowThreshold: 慢查询阈值
+//   - dbType: 数据库类型
+// 返回值:
+//   - *sampler: 采样器，未启用时返回nil
+func newSampler(config SamplingConfig, slowThreshold time.Duration, dbType string) *sampler {
+	if !config.Enabled {
+		return nil
+	}
+
+	s := &sampler{
+		initial:       uint64(config.Initial),
+		thereafter:    uint64(config.Thereafter),
+		interval:      config.Interval,
+		slowThreshold: slowThreshold,
+		dbType:        dbType,
+	}
+	if s.interval <= 0 {
+		s.interval = defaultSampleInterval
+	}
+	if s.slowThreshold <= 0 {
+		s.slowThreshold = defaultSampleSlowThreshold
+	}
+	return s
+}
+
+// allow 判断本条SQL日志是否需要记录
+// 参数:
+//   - sql: SQL语句
+//   - elapsed: 执行耗时
+//   - err: 执行错误
+// 返回值:
+//   - bool: 是否记录
+func (s *sampler) allow(sql string, elapsed time.Duration, err error) bool {
+	if err != nil || elapsed >= s.slowThreshold {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(fingerprintSQL(sql, s.dbType)))
+	counter := &s.counters[h.Sum32()%samplerBuckets]
+
+	n := counter.incCheckReset(time.Now().UnixNano(), s.interval)
+	if n <= s.initial {
+		return true
+	}
+	if s.thereafter > 0 && (n-s.initial)%s.thereafter == 0 {
+		return true
+	}
+	return false
+}
+
+// fingerprintSQL 计算SQL指纹
+// 字面量、数字和占位符统一替换为?，IN列表折叠为(?+)，空白压缩为单个空格
+// 参数:
+//   - sql: SQL语句
+//   - dbType: 数据库类型，PostgreSQL中双引号表示标识符，其余方言表示字符串
+// 返回值:
+//   - string: SQL指纹
+func fingerprintSQL(sql, dbType string) string {
+	doubleQuotedLiteral := dialectFamily(dbType) != "postgres"
+
+	var b strings.Builder
+	b.Grow(len(sql))
+	space := false
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		switch {
+		case c == '\'' || (c == '"' && doubleQuotedLiteral):
+			i = skipQuoted(sql, i, c)
+			b.WriteByte('?')
+		case c == '"' || c == '`':
+			end := skipQuoted(sql, i, c)
+			b.WriteString(sql[i:end])
+			i = end
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			i++
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+			b.WriteByte('?')
+		case isDigit(c) && !endsWithIdent(&b):
+			for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.' || sql[i] == 'e' || sql[i] == 'E') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return collapseValueLists(b.String())
+}
+
+// skipQuoted 跳过以quote开头的引号内容
+// 参数:
+//   - sql: SQL语句
+//   - i: 起始引号下标
+//   - quote: 引号字符
+// 返回值:
+//   - int: 结束引号之后的下标
+func skipQuoted(sql string, i int, quote byte) int {
+	for i++; i < len(sql); i++ {
+		if sql[i] == '\\' {
+			i++
+			continue
+		}
+		if sql[i] == quote {
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// endsWithIdent 判断已输出内容是否以标识符字符结尾，用于区分 t1 中的数字
+func endsWithIdent(b *strings.Builder) bool {
+	s := b.String()
+	if len(s) == 0 {
+		return false
+	}
+	c := s[len(s)-1]
+	return isIdentStart(c) || isDigit(c)
+}
+
+// collapseValueLists 将 (?,?,?) 形式的列表折叠为 (?+)
+// 参数:
+//   - s: 已归一化的SQL
+// 返回值:
+//   - string: 折叠后的SQL
+func collapseValueLists(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); {
+		if s[i] == '(' {
+			j := i + 1
+			count := 0
+			for j < len(s) {
+				for j < len(s) && s[j] == ' ' {
+					j++
+				}
+				if j >= len(s) || s[j] != '?' {
+					break
+				}
+				count++
+				j++
+				for j < len(s) && s[j] == ' ' {
+					j++
+				}
+				if j < len(s) && s[j] == ',' {
+					j++
+					continue
+				}
+				break
+			}
+			if count > 0 && j < len(s) && s[j] == ')' {
+				b.WriteString("(?+)")
+				i = j + 1
+				// 多行VALUES同样折叠
+				for {
+					k := i
+					for k < len(s) && (s[k] == ' ' || s[k] == ',') {
+						k++
+					}
+					if !strings.HasPrefix(s[k:], "(?") {
+						break
+					}
+					end := strings.IndexByte(s[k:], ')')
+					if end < 0 || strings.Trim(s[k+1:k+end], "?, ") != "" {
+						break
+					}
+					i = k + end + 1
+				}
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+
+	return b.String()
+}
@@ -0,0 +1,28 @@
+//go:build cgo && !sqlite_purego
+
+package database
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// 默认的SQLite方言，基于mattn/go-sqlite3，需要CGO
+func init() {
+	registerDialect(&dialect{
+		name:        "sqlite",
+		factory:     sqlite.Open,
+		family:      "sqlite",
+		driverName:  sqlite.DriverName,
+		setConnPool: setSQLiteConnPool,
+	}, "sqlite3")
+}
+
+// setSQLiteConnPool 设置SQLite方言的连接池
+func setSQLiteConnPool(dialector gorm.Dialector, pool gorm.ConnPool) bool {
+	d, ok := dialector.(*sqlite.Dialector)
+	if ok {
+		d.Conn = pool
+	}
+	return ok
+}
@@ -0,0 +1,17 @@
+//go:build !cgo || sqlite_purego
+
+package database
+
+import puresqlite "github.com/glebarez/sqlite"
+
+// 未启用CGO或指定 sqlite_purego 标签时，"sqlite" 使用纯Go实现，
+// 不再链接mattn/go-sqlite3
+func init() {
+	registerDialect(&dialect{
+		name:        "sqlite",
+		factory:     puresqlite.Open,
+		family:      "sqlite",
+		driverName:  puresqlite.DriverName,
+		setConnPool: setPureSQLiteConnPool,
+	}, "sqlite3")
+}
@@ -0,0 +1,27 @@
+package database
+
+import (
+	puresqlite "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// 纯Go实现的SQLite方言，不依赖CGO，适用于静态编译和交叉编译
+// Type设置为 "sqlite-purego" 时使用；以 sqlite_purego 标签或 CGO_ENABLED=0 编译时 "sqlite" 同样使用该实现
+func init() {
+	registerDialect(&dialect{
+		name:        "sqlite-purego",
+		factory:     puresqlite.Open,
+		family:      "sqlite",
+		driverName:  puresqlite.DriverName,
+		setConnPool: setPureSQLiteConnPool,
+	})
+}
+
+// setPureSQLiteConnPool 设置纯Go SQLite方言的连接池
+func setPureSQLiteConnPool(dialector gorm.Dialector, pool gorm.ConnPool) bool {
+	d, ok := dialector.(*puresqlite.Dialector)
+	if ok {
+		d.Conn = pool
+	}
+	return ok
+}
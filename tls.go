@@ -0,0 +1,197 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// TLSConfig TLS配置结构体
+// 优先于DSN和ConnectionConfig.TLS中的TLS设置；证书文件在每次建立连接时重新读取，便于证书轮换
+type TLSConfig struct {
+	// 校验模式 (disable, require, verify-ca, verify-full)，为空且配置了证书文件时为verify-full
+	Mode string `json:"mode" yaml:"mode" mapstructure:"mode"`
+	// CA证书文件路径，为空时使用系统根证书
+	CAFile string `json:"ca_file" yaml:"ca_file" mapstructure:"ca_file"`
+	// 客户端证书文件路径
+	CertFile string `json:"cert_file" yaml:"cert_file" mapstructure:"cert_file"`
+	// 客户端私钥文件路径
+	KeyFile string `json:"key_file" yaml:"key_file" mapstructure:"key_file"`
+	// 校验证书时使用的服务端名称，为空时使用连接地址中的主机名
+	ServerName string `json:"server_name" yaml:"server_name" mapstructure:"server_name"`
+}
+
+// IsZero 判断是否未配置TLS
+// 返回值:
+//   - bool: 是否为空
+func (c TLSConfig) IsZero() bool {
+	return c == TLSConfig{}
+}
+
+// mode 返回生效的校验模式
+func (c TLSConfig) mode() string {
+	if c.Mode == "" {
+		return TLSModeVerifyFull
+	}
+	return c.Mode
+}
+
+// validate 校验TLS配置，并尝试加载证书文件以提前发现错误
+// 参数:
+//   - dbType: 数据库类型
+// 返回值:
+//   - error: 校验错误
+func (c TLSConfig) validate(dbType string) error {
+	if c.IsZero() {
+		return nil
+	}
+
+	switch family := dialectFamily(dbType); family {
+	case "mysql", "postgres":
+	case "sqlite":
+		return fmt.Errorf("sqlite does not support tls")
+	default:
+		return fmt.Errorf("%s does not support tls config", dbType)
+	}
+
+	switch c.mode() {
+	case TLSModeDisable, TLSModeRequire, TLSModeVerifyCA, TLSModeVerifyFull:
+	default:
+		return fmt.Errorf("unsupported tls mode: %s", c.Mode)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("tls cert_file and key_file must be set together")
+	}
+
+	_, err := c.build("")
+	return err
+}
+
+// build 生成crypto/tls配置
+// 参数:
+//   - host: 连接地址中的主机名，ServerName为空时用于校验证书
+// 返回值:
+//   - *tls.Config: TLS配置，disable模式下为nil
+//   - error: 读取证书失败时返回错误
+func (c TLSConfig) build(host string) (*tls.Config, error) {
+	mode := c.mode()
+	if mode == TLSModeDisable {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls ca file %s", c.CAFile)
+		}
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	switch mode {
+	case TLSModeRequire:
+		config.InsecureSkipVerify = true
+	case TLSModeVerifyCA:
+		// 只校验证书链，不校验主机名
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyCertificateChain(config.RootCAs)
+	}
+	return config, nil
+}
+
+// verifyCertificateChain 返回只校验证书链的回调
+// 参数:
+//   - roots: 根证书，为nil时使用系统根证书
+// 返回值:
+//   - func: 证书校验回调
+func verifyCertificateChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("tls: server did not present a certificate")
+		}
+
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
+
+// openTLSConnector 按方言创建应用TLS配置的驱动连接器
+// 参数:
+//   - dbType: 数据库类型
+//   - dsn: 数据源名称
+//   - config: TLS配置
+// 返回值:
+//   - driver.Connector: 驱动连接器
+//   - error: 错误信息
+func openTLSConnector(dbType, dsn string, config TLSConfig) (driver.Connector, error) {
+	switch dialectFamily(dbType) {
+	case "mysql":
+		cfg, err := mysqldriver.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			host = cfg.Addr
+		}
+		if cfg.TLS, err = config.build(host); err != nil {
+			return nil, err
+		}
+		if cfg.TLS == nil {
+			cfg.TLSConfig = "false"
+		}
+		return mysqldriver.NewConnector(cfg)
+	case "postgres":
+		cfg, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.TLSConfig, err = config.build(cfg.Host); err != nil {
+			return nil, err
+		}
+		// 明确指定了TLS模式，不再回退到其他sslmode
+		cfg.Fallbacks = nil
+		return stdlib.GetConnector(*cfg), nil
+	default:
+		return nil, fmt.Errorf("%s does not support tls config", dbType)
+	}
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTransactionTimeout 事务运行超过超时时间，已被取消并回滚
+var ErrTransactionTimeout = errors.New("transaction timeout")
+
+// maxStackDepth 记录开启事务调用栈的最大深度
+const maxStackDepth = 32
+
+// managerFramePrefix 管理器方法在调用栈中的函数名前缀，记录调用栈时跳过
+var managerFramePrefix = reflect.TypeOf((*DBManager)(nil)).Elem().PkgPath() + ".(*DBManager)."
+
+// TransactionInfo 进行中的事务信息
+type TransactionInfo struct {
+	// ID 事务ID，在管理器内唯一
+	ID uint64 `json:"id"`
+	// Node 执行事务的节点 (master, replica)
+	Node string `json:"node"`
+	// ReadOnly 是否为只读事务
+	ReadOnly bool `json:"read_only"`
+	// Isolation 隔离级别，为空时为数据库默认级别
+	Isolation string `json:"isolation"`
+	// StartedAt 开启时间
+	StartedAt time.Time `json:"started_at"`
+	// Duration 已运行时间
+	Duration time.Duration `json:"duration"`
+	// Statements 已执行的SQL语句数
+	Statements int64 `json:"statements"`
+	// Stack 开启事务的调用栈
+	Stack string `json:"stack"`
+}
+
+// activeTx 进行中的事务
+type activeTx struct {
+	// info 事务信息，Duration和Stack在获取快照时生成
+	info TransactionInfo
+	// ctx 事务上下文，用于记录日志
+	ctx context.Context
+	// pcs 开启事务的调用栈
+	pcs []uintptr
+	// timer 长事务检测定时器
+	timer *time.Timer
+	// statements 已执行的SQL语句数
+	statements atomic.Int64
+}
+
+// snapshot 生成事务信息快照
+// 返回值:
+//   - TransactionInfo: 事务信息
+func (t *activeTx) snapshot() TransactionInfo {
+	info := t.info
+	info.Duration = time.Since(info.StartedAt)
+	info.Statements = t.statements.Load()
+	info.Stack = formatStack(t.pcs)
+	return info
+}
+
+// ActiveTransactions 获取进行中的事务
+// 包括通过Transaction和TransactionWithOptions开启的最外层事务，按开启时间排序
+// 返回值:
+//   - []TransactionInfo: 事务信息列表
+func (m *DBManager) ActiveTransactions() []TransactionInfo {
+	var result []TransactionInfo
+	m.activeTxs.Range(func(_, value interface{}) bool {
+		result = append(result, value.(*activeTx).snapshot())
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// trackTransaction 登记进行中的事务，并在超过长事务阈值时告警
+// 参数:
+//   - ctx: 事务上下文
+//   - id: 事务ID
+//   - opts: 事务选项
+//   - config: 事务配置
+// 返回值:
+//   - *activeTx: 进行中的事务
+func (m *DBManager) trackTransaction(ctx context.Context, id uint64, opts TxOptions, config TransactionConfig) *activeTx {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(2, pcs[:])
+
+	m.txMetrics.begun.Add(1)
+	t := &activeTx{
+		info: TransactionInfo{
+			ID:        id,
+			Node:      "master",
+			ReadOnly:  opts.ReadOnly,
+			Isolation: opts.Isolation,
+			StartedAt: time.Now(),
+		},
+		ctx: ctx,
+		pcs: pcs[:n],
+	}
+	if opts.UseReplica {
+		t.info.Node = "replica"
+	}
+	m.activeTxs.Store(t.info.ID, t)
+	m.txMetrics.active.Add(1)
+
+	if threshold := config.LongRunningThreshold; threshold > 0 {
+		t.timer = time.AfterFunc(threshold, func() {
+			m.reportLongRunning(t, config.OnLongRunning)
+		})
+	}
+	return t
+}
+
+// untrackTransaction 移除已结束的事务，记录统计信息并通知OnFinish回调
+// 参数:
+//   - t: 进行中的事务
+//   - config: 事务配置
+//   - outcome: 事务结果
+//   - err: 回滚原因
+func (m *DBManager) untrackTransaction(t *activeTx, config TransactionConfig, outcome string, err error) {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	m.activeTxs.Delete(t.info.ID)
+	m.txMetrics.active.Add(-1)
+
+	info := t.info
+	info.Duration = time.Since(info.StartedAt)
+	info.Statements = t.statements.Load()
+	m.txMetrics.observe(outcome, info.Duration, info.Statements, err)
+
+	if config.OnFinish == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.Error(t.ctx, "transaction finish callback panicked: %v", r)
+		}
+	}()
+	config.OnFinish(TransactionEvent{TransactionInfo: info, Outcome: outcome, Err: err})
+}
+
+// reportLongRunning 报告运行超过阈值的事务
+// 参数:
+//   - t: 进行中的事务
+//   - callback: 长事务回调，可为nil
+func (m *DBManager) reportLongRunning(t *activeTx, callback func(info TransactionInfo)) {
+	info := t.snapshot()
+	m.logger.Warn(t.ctx, "transaction %d on %s has been running for %s, started at:\n%s",
+		info.ID, info.Node, info.Duration.Round(time.Millisecond), info.Stack)
+
+	if callback == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.Error(t.ctx, "long running transaction callback panicked: %v", r)
+		}
+	}()
+	callback(info)
+}
+
+// formatStack 格式化调用栈，跳过管理器内部的调用
+// 参数:
+//   - pcs: 调用栈
+// 返回值:
+//   - string: 调用栈，每帧两行：函数名、文件和行号
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	internal := true
+	for {
+		frame, more := frames.Next()
+		if internal && strings.HasPrefix(frame.Function, managerFramePrefix) {
+			if !more {
+				break
+			}
+			continue
+		}
+		internal = false
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// wrapTimeout 事务因自身超时被取消时，在错误中标明ErrTransactionTimeout
+// 参数:
+//   - parent: 调用方上下文
+//   - txCtx: 事务上下文
+//   - timeout: 事务超时时间
+//   - err: 原始错误
+// 返回值:
+//   - error: 错误信息
+func wrapTimeout(parent, txCtx context.Context, timeout time.Duration, err error) error {
+	if timeout > 0 && parent.Err() == nil && errors.Is(txCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %w", ErrTransactionTimeout, timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,450 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// txKey 活动事务的上下文键
+type txKey struct{}
+
+// txValue 上下文中保存的活动事务
+type txValue struct {
+	// manager 开启事务的管理器，避免误用其他管理器的事务
+	manager *DBManager
+	// tx 事务实例
+	tx *gorm.DB
+	// opts 开启事务时的选项
+	opts TxOptions
+	// active 最外层事务的跟踪信息
+	active *activeTx
+	// parent 外层事务，保存点时不为nil
+	parent *txValue
+	// mu 保护事务状态和回调
+	mu sync.Mutex
+	// state 事务状态
+	state txState
+	// afterCommit 提交后执行的回调
+	afterCommit []func()
+	// afterRollback 回滚后执行的回调
+	afterRollback []func()
+	// afterSavepoint 已回滚保存点中注册的回滚回调，仅最外层事务使用，最外层事务结束后执行
+	afterSavepoint []func()
+}
+
+// withTx 将事务保存到上下文，并让事务实例携带该上下文
+// 参数:
+//   - ctx: 上下文
+//   - m: 开启事务的管理器
+//   - tx: 事务实例
+//   - opts: 事务选项
+// 返回值:
+//   - *txValue: 活动事务，其tx携带新上下文
+func withTx(ctx context.Context, m *DBManager, tx *gorm.DB, opts TxOptions) *txValue {
+	value := &txValue{manager: m, opts: opts}
+	value.tx = tx.WithContext(context.WithValue(ctx, txKey{}, value))
+	return value
+}
+
+// root 返回最外层事务
+// 返回值:
+//   - *txValue: 最外层事务
+func (v *txValue) root() *txValue {
+	for v.parent != nil {
+		v = v.parent
+	}
+	return v
+}
+
+// countStatement 记录事务中执行的一条语句
+func (v *txValue) countStatement() {
+	if v.active != nil {
+		v.active.statements.Add(1)
+	}
+}
+
+// savepoint 在活动事务中以保存点执行fn
+// 保存点拥有独立的回调作用域：释放时回调转交给外层事务，回滚时丢弃AfterCommit回调并立即执行AfterRollback回调
+// 参数:
+//   - fn: 事务执行函数
+// 返回值:
+//   - error: 错误信息
+func (v *txValue) savepoint(fn func(tx *gorm.DB) error) (err error) {
+	child := &txValue{manager: v.manager, opts: v.opts, parent: v}
+
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			child.finish(false)
+		} else {
+			child.release()
+		}
+	}()
+
+	err = v.tx.Transaction(func(tx *gorm.DB) error {
+		child.tx = tx.WithContext(context.WithValue(tx.Statement.Context, txKey{}, child))
+		return fn(child.tx)
+	})
+	panicked = false
+	return err
+}
+
+// txFromContext 获取上下文中由该管理器开启的活动事务
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - *txValue: 活动事务，不存在时为nil
+func (m *DBManager) txFromContext(ctx context.Context) *txValue {
+	if ctx == nil {
+		return nil
+	}
+	value, ok := ctx.Value(txKey{}).(*txValue)
+	if !ok || value.manager != m {
+		return nil
+	}
+	return value
+}
+
+// 事务隔离级别
+const (
+	// IsolationReadCommitted 读已提交
+	IsolationReadCommitted = "read_committed"
+	// IsolationRepeatableRead 可重复读
+	IsolationRepeatableRead = "repeatable_read"
+	// IsolationSerializable 可串行化
+	IsolationSerializable = "serializable"
+)
+
+// 事务重试的默认退避参数
+const (
+	// defaultRetryBackoff 首次重试前的默认等待时间
+	defaultRetryBackoff = 10 * time.Millisecond
+	// defaultRetryMaxBackoff 重试等待时间的默认上限
+	defaultRetryMaxBackoff = time.Second
+)
+
+// TxOptions 事务选项
+type TxOptions struct {
+	// 最大尝试次数（包含首次执行），小于等于1时不重试
+	MaxAttempts int
+	// 首次重试前的等待时间，之后每次翻倍，为0时为10毫秒；实际等待时间在[0, 当前值)内随机
+	Backoff time.Duration
+	// 重试等待时间上限，为0时为1秒
+	MaxBackoff time.Duration
+	// 判断错误是否可重试，为nil时使用IsRetryableError
+	RetryIf func(err error) bool
+	// 每次重试前调用，attempt为失败的尝试序号（从1开始）
+	OnRetry func(attempt int, err error)
+	// 隔离级别 (read_committed, repeatable_read, serializable)，为空时使用数据库默认级别；
+	// SQLite的事务总是可串行化的，只接受serializable
+	Isolation string
+	// 是否为只读事务，SQLite不支持
+	ReadOnly bool
+	// 只读事务是否在从库执行，需同时设置ReadOnly；未配置从库时仍在主库执行
+	UseReplica bool
+	// 每次尝试的超时时间，超时后取消事务上下文并回滚，为0时使用TransactionConfig.Timeout
+	Timeout time.Duration
+	// 幂等键，非空时随事务写入transaction_keys表（需先调用MigrateTransactionKeys）；
+	// 提交结果未知时查询该键判断事务是否已生效，键已存在时不执行fn并返回ErrAlreadyCommitted
+	IdempotencyKey string
+}
+
+// validate 按数据库类型校验事务选项
+// 参数:
+//   - dbType: 数据库类型
+// 返回值:
+//   - error: 校验错误
+func (o TxOptions) validate(dbType string) error {
+	switch o.Isolation {
+	case "", IsolationReadCommitted, IsolationRepeatableRead, IsolationSerializable:
+	default:
+		return fmt.Errorf("unsupported isolation level: %s", o.Isolation)
+	}
+	if o.UseReplica && !o.ReadOnly {
+		return fmt.Errorf("UseReplica requires a read-only transaction")
+	}
+	if o.Timeout < 0 {
+		return fmt.Errorf("transaction timeout cannot be negative")
+	}
+
+	if dialectFamily(dbType) == "sqlite" {
+		// SQLite驱动会静默忽略隔离级别和只读选项，这里明确报错
+		if o.Isolation != "" && o.Isolation != IsolationSerializable {
+			return fmt.Errorf("sqlite only supports serializable isolation, got %s", o.Isolation)
+		}
+		if o.ReadOnly {
+			return fmt.Errorf("sqlite does not support read-only transactions")
+		}
+	}
+	return nil
+}
+
+// sqlTxOptions 转换为database/sql的事务选项
+// 返回值:
+//   - *sql.TxOptions: 事务选项，均为默认值时为nil
+func (o TxOptions) sqlTxOptions() *sql.TxOptions {
+	level := sql.LevelDefault
+	switch o.Isolation {
+	case IsolationReadCommitted:
+		level = sql.LevelReadCommitted
+	case IsolationRepeatableRead:
+		level = sql.LevelRepeatableRead
+	case IsolationSerializable:
+		level = sql.LevelSerializable
+	}
+	if level == sql.LevelDefault && !o.ReadOnly {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: level, ReadOnly: o.ReadOnly}
+}
+
+// TransactionError 经过重试后仍然失败的事务错误
+type TransactionError struct {
+	// Attempts 已尝试的次数
+	Attempts int
+	// Err 最后一次尝试的错误
+	Err error
+}
+
+// Error 返回错误信息
+// 返回值:
+//   - string: 错误信息
+func (e *TransactionError) Error() string {
+	return fmt.Sprintf("transaction failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+// Unwrap 返回最后一次尝试的错误
+// 返回值:
+//   - error: 原始错误
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// TransactionWithOptions 按选项执行事务
+// fn或提交返回可重试的错误（死锁、序列化失败等）时，回滚并在退避后从头重新执行fn，
+// 因此fn中不应包含无法重复执行的外部副作用。发生过重试的失败以*TransactionError返回，其中包含尝试次数。
+// 在已有事务中调用时以保存点执行且不重试，重试由最外层事务负责；
+// 保存点沿用外层事务的隔离级别和只读属性，要求不同隔离级别时返回错误。
+// fn返回的错误原样返回（超时时包装为ErrTransactionTimeout）；提交失败的错误可用errors.Is区分：
+// ErrRolledBack（提交前事务已被回滚）、ErrCommitFailed（提交被数据库拒绝）和ErrCommitUnknown（提交时连接中断，结果未知）
+// 参数:
+//   - ctx: 上下文
+//   - opts: 事务选项
+//   - fn: 事务执行函数
+// 返回值:
+//   - error: 错误信息
+func (m *DBManager) TransactionWithOptions(ctx context.Context, opts TxOptions, fn func(tx *gorm.DB) error) error {
+	if fn == nil {
+		return fmt.Errorf("transaction function cannot be nil")
+	}
+
+	// 嵌套事务使用保存点
+	if parent := m.txFromContext(ctx); parent != nil {
+		if opts.Isolation != "" && opts.Isolation != parent.opts.Isolation {
+			return fmt.Errorf("nested transaction cannot change isolation level to %s", opts.Isolation)
+		}
+		if opts.IdempotencyKey != "" {
+			return fmt.Errorf("IdempotencyKey is not supported in nested transactions")
+		}
+		return parent.savepoint(fn)
+	}
+
+	m.mu.RLock()
+	dbType := m.config.Type
+	m.mu.RUnlock()
+	if err := opts.validate(dbType); err != nil {
+		return fmt.Errorf("invalid transaction options: %w", err)
+	}
+
+	retryIf := opts.RetryIf
+	if retryIf == nil {
+		retryIf = IsRetryableError
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := m.runTransaction(ctx, opts, fn)
+		if err == nil {
+			return nil
+		}
+		if attempt >= opts.MaxAttempts || !retryIf(err) {
+			if attempt > 1 {
+				return &TransactionError{Attempts: attempt, Err: err}
+			}
+			return err
+		}
+
+		m.txMetrics.retries.Add(1)
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt, err)
+		}
+		delay := retryBackoff(opts, attempt)
+		m.logger.Warn(ctx, "transaction attempt %d failed, retrying in %s: %v", attempt, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &TransactionError{Attempts: attempt, Err: err}
+		case <-timer.C:
+		}
+	}
+}
+
+// runTransaction 执行一次事务
+// 参数:
+//   - ctx: 上下文
+//   - opts: 事务选项
+//   - fn: 事务执行函数
+// 返回值:
+//   - error: 错误信息
+func (m *DBManager) runTransaction(ctx context.Context, opts TxOptions, fn func(tx *gorm.DB) error) error {
+	m.mu.RLock()
+	dbType, config := m.config.Type, m.config.TransactionConfig
+	m.mu.RUnlock()
+
+	// 超时后database/sql会回滚事务，之后的语句和提交都会失败
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = config.Timeout
+	}
+	txCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		txCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// 默认使用主库执行事务
+	db := m.GetMasterDB()
+	if opts.UseReplica {
+		db = m.GetSlaveDB()
+	}
+
+	// 事务内的SQL日志携带事务ID
+	id := m.txSeq.Add(1)
+	txCtx = WithLogFields(txCtx, LogFieldTxID, id)
+
+	tx := db.WithContext(txCtx).Begin(opts.sqlTxOptions())
+	if tx.Error != nil {
+		return wrapTimeout(ctx, txCtx, timeout, fmt.Errorf("failed to begin transaction: %w", tx.Error))
+	}
+	value := withTx(txCtx, m, tx, opts)
+	tx = value.tx
+	value.active = m.trackTransaction(txCtx, id, opts, config)
+
+	// 未正常返回时即为panic
+	outcome, err := TxOutcomePanicked, error(nil)
+	defer func() {
+		m.untrackTransaction(value.active, config, outcome, err)
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			value.finish(false)
+			panic(r)
+		}
+	}()
+
+	if opts.IdempotencyKey != "" {
+		err = recordTransactionKey(tx, opts.IdempotencyKey)
+	}
+	if err == nil {
+		err = fn(tx)
+	}
+	if err != nil {
+		tx.Rollback()
+		value.finish(false)
+		outcome, err = TxOutcomeRolledBack, wrapTimeout(ctx, txCtx, timeout, err)
+		return err
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		result := classifyCommit(dbType, err)
+		if result == commitUnknown && opts.IdempotencyKey != "" {
+			result = m.resolveCommit(ctx, opts.IdempotencyKey)
+		}
+
+		switch result {
+		case commitCommitted:
+			m.logger.Warn(txCtx, "commit returned %v, but idempotency key %s shows the transaction was committed", err, opts.IdempotencyKey)
+			value.finish(true)
+			outcome, err = TxOutcomeCommitted, nil
+			return nil
+		case commitUnknown:
+			// 无法确定是否提交，两类回调都不执行
+			value.abandon()
+			outcome = TxOutcomeCommitUnknown
+		default:
+			value.finish(false)
+			outcome = TxOutcomeRolledBack
+		}
+		err = wrapTimeout(ctx, txCtx, timeout, commitError(result, err))
+		return err
+	}
+
+	value.finish(true)
+	outcome = TxOutcomeCommitted
+	return nil
+}
+
+// retryBackoff 计算第attempt次失败后的等待时间
+// 采用指数退避和完全抖动
+// 参数:
+//   - opts: 事务选项
+//   - attempt: 失败的尝试序号
+// 返回值:
+//   - time.Duration: 等待时间
+func retryBackoff(opts TxOptions, attempt int) time.Duration {
+	backoff, maxBackoff := opts.Backoff, opts.MaxBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return rand.N(backoff)
+}
+
+// IsRetryableError 判断错误是否为重新执行整个事务即可能成功的临时错误
+// 包括MySQL死锁(1213)、PostgreSQL死锁(40P01)和序列化失败(40001)，以及SQLite数据库被锁定
+// 参数:
+//   - err: 错误
+// 返回值:
+//   - bool: 是否可重试
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40P01" || pgErr.Code == "40001"
+	}
+
+	// SQLite驱动（CGO与纯Go实现）的错误类型不同，按错误信息判断
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
@@ -0,0 +1,408 @@
+// Package twophase 跨多个数据库的两阶段提交协调器
+// 在每个参与者上开启分支事务（MySQL使用XA事务，PostgreSQL使用PREPARE TRANSACTION）并执行业务函数，
+// 全部准备成功后先在恢复日志中记录提交决定，再逐个提交。进程在提交过程中崩溃时，
+// 重启后由Recover根据恢复日志提交或回滚遗留的预提交事务。
+// 协调器是尽力而为的：恢复日志与参与者不在同一事务中，极端故障下仍可能需要人工介入。SQLite不支持两阶段提交
+package twophase
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"database"
+
+	"gorm.io/gorm"
+)
+
+// 协调器的默认配置
+const (
+	// defaultPrefix 默认全局事务ID前缀
+	defaultPrefix = "tpc"
+	// defaultRecoveryGrace 默认恢复宽限期
+	defaultRecoveryGrace = time.Minute
+	// defaultRecoveryInterval 默认后台恢复间隔
+	defaultRecoveryInterval = time.Minute
+)
+
+// 两阶段提交的错误
+var (
+	// ErrNotSupported 参与者的数据库不支持两阶段提交
+	ErrNotSupported = errors.New("twophase: two-phase commit is not supported")
+	// ErrInDoubt 已决定提交，但部分参与者提交失败，需由Recover完成提交
+	ErrInDoubt = errors.New("twophase: transaction in doubt")
+)
+
+// namePattern 前缀和参与者名称的合法格式，会直接拼接到事务标识中
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// Config 协调器配置结构体
+type Config struct {
+	// 全局事务ID前缀，用于恢复时识别本协调器创建的事务，共用数据库的不同应用应使用不同前缀，默认tpc
+	Prefix string `json:"prefix" yaml:"prefix" mapstructure:"prefix"`
+	// 恢复宽限期，恢复时跳过状态在宽限期内变更过的全局事务，避免中止仍在执行的事务，默认1分钟；
+	// 只影响恢复的及时性，超过宽限期仍在执行的事务被恢复流程中止后，Run回滚并返回错误
+	RecoveryGrace time.Duration `json:"recovery_grace" yaml:"recovery_grace" mapstructure:"recovery_grace"`
+	// 后台恢复间隔，默认1分钟
+	RecoveryInterval time.Duration `json:"recovery_interval" yaml:"recovery_interval" mapstructure:"recovery_interval"`
+}
+
+// participant 参与两阶段提交的数据库
+type participant struct {
+	// name 参与者名称，作为分支标识
+	name string
+	// manager 数据库管理器
+	manager database.Manager
+	// dialect 两阶段提交语句
+	dialect xaDialect
+}
+
+// runner 在管理器生命周期内运行后台任务并提供日志记录器，*database.DBManager实现了该接口
+type runner interface {
+	Go(fn func(ctx context.Context)) error
+	Logger() database.Logger
+}
+
+// Coordinator 两阶段提交协调器
+type Coordinator struct {
+	// log 保存恢复日志的数据库管理器
+	log database.Manager
+	// runner 运行后台恢复并记录日志，即log本身
+	runner runner
+	// participants 参与者，按名称排序
+	participants []*participant
+	// config 协调器配置
+	config Config
+}
+
+// NewCoordinator 创建两阶段提交协调器
+// 参数:
+//   - log: 保存恢复日志的数据库管理器，需先调用Migrate创建日志表，可以是参与者之一；需实现Go和Logger方法，如NewManager返回的管理器
+//   - participants: 参与者，键为参与者名称，只能包含字母、数字和_.-，且重启后保持不变
+//   - config: 协调器配置，零值字段使用默认值
+// 返回值:
+//   - *Coordinator: 两阶段提交协调器
+//   - error: 参与者的数据库不支持两阶段提交时返回ErrNotSupported
+func NewCoordinator(log database.Manager, participants map[string]database.Manager, config Config) (*Coordinator, error) {
+	if log == nil {
+		return nil, fmt.Errorf("twophase: log manager cannot be nil")
+	}
+	runner, ok := log.(runner)
+	if !ok {
+		return nil, fmt.Errorf("twophase: log manager %T does not support background tasks", log)
+	}
+	if len(participants) == 0 {
+		return nil, fmt.Errorf("twophase: at least one participant is required")
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = defaultPrefix
+	}
+	if !namePattern.MatchString(config.Prefix) {
+		return nil, fmt.Errorf("twophase: invalid prefix %q", config.Prefix)
+	}
+	if config.RecoveryGrace <= 0 {
+		config.RecoveryGrace = defaultRecoveryGrace
+	}
+	if config.RecoveryInterval <= 0 {
+		config.RecoveryInterval = defaultRecoveryInterval
+	}
+
+	c := &Coordinator{log: log, runner: runner, config: config}
+	for name, manager := range participants {
+		if !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("twophase: invalid participant name %q", name)
+		}
+		if manager == nil {
+			return nil, fmt.Errorf("twophase: participant %s cannot be nil", name)
+		}
+		dialectName := manager.GetMasterDB().Dialector.Name()
+		dialect, ok := dialects[dialectName]
+		if !ok {
+			return nil, fmt.Errorf("%w by %s (participant %s)", ErrNotSupported, dialectName, name)
+		}
+		c.participants = append(c.participants, &participant{name: name, manager: manager, dialect: dialect})
+	}
+	sort.Slice(c.participants, func(i, j int) bool {
+		return c.participants[i].name < c.participants[j].name
+	})
+	return c, nil
+}
+
+// branchConn 分支事务的连接
+// 实现gorm.TxCommitter，使dbresolver等插件将其视为事务，所有语句都在分支事务的连接上执行；
+// 分支事务由协调器提交或回滚，直接调用Commit或Rollback时返回错误
+type branchConn struct {
+	*sql.Conn
+}
+
+// Commit 分支事务由协调器提交
+// 返回值:
+//   - error: 总是返回错误
+func (*branchConn) Commit() error {
+	return fmt.Errorf("twophase: branch transactions are committed by the coordinator")
+}
+
+// Rollback 分支事务由协调器回滚
+// 返回值:
+//   - error: 总是返回错误
+func (*branchConn) Rollback() error {
+	return fmt.Errorf("twophase: branch transactions are rolled back by the coordinator")
+}
+
+// branch 参与者上的分支事务
+type branch struct {
+	// participant 参与者
+	participant *participant
+	// xid 分支事务标识
+	xid xid
+	// conn 分支事务使用的连接
+	conn *sql.Conn
+	// prepared 是否已准备
+	prepared bool
+	// done 是否已提交或回滚
+	done bool
+}
+
+// release 归还分支事务的连接
+// 未正常结束的连接可能仍处于事务中或关联着预提交事务，直接关闭而不放回连接池
+func (b *branch) release() {
+	if !b.done {
+		b.conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	b.conn.Close()
+}
+
+// Run 在所有参与者上以两阶段提交执行fn
+// fn返回错误或panic时回滚所有参与者并原样返回错误；准备失败时回滚所有参与者；
+// 全部准备成功后在恢复日志中记录提交决定，之后部分参与者提交失败或无法确认提交决定时返回ErrInDoubt，由Recover完成。
+// 提交阶段不受ctx取消的影响
+// 参数:
+//   - ctx: 上下文
+//   - fn: 事务执行函数，txs的键为参与者名称，值为对应分支事务的实例
+// 返回值:
+//   - error: 错误信息
+func (c *Coordinator) Run(ctx context.Context, fn func(txs map[string]*gorm.DB) error) error {
+	if fn == nil {
+		return fmt.Errorf("twophase: transaction function cannot be nil")
+	}
+
+	gtrid, err := c.newID()
+	if err != nil {
+		return err
+	}
+	entry := &LogEntry{ID: gtrid, Participants: c.participantNames(), Status: StatusPreparing}
+	if err := c.log.GetMasterDB().WithContext(ctx).Create(entry).Error; err != nil {
+		return fmt.Errorf("twophase: failed to write recovery log: %w", err)
+	}
+
+	var branches []*branch
+	defer func() {
+		for _, b := range branches {
+			b.release()
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			c.abort(ctx, entry, branches)
+			panic(r)
+		}
+	}()
+
+	txs := make(map[string]*gorm.DB, len(c.participants))
+	for _, p := range c.participants {
+		b, tx, err := c.begin(ctx, p, gtrid)
+		if err != nil {
+			c.abort(ctx, entry, branches)
+			return err
+		}
+		branches = append(branches, b)
+		txs[p.name] = tx
+	}
+
+	if err := fn(txs); err != nil {
+		c.abort(ctx, entry, branches)
+		return err
+	}
+
+	for _, b := range branches {
+		if err := b.participant.dialect.prepare(ctx, b.conn, b.xid); err != nil {
+			c.abort(ctx, entry, branches)
+			return fmt.Errorf("twophase: failed to prepare %s: %w", b.participant.name, err)
+		}
+		b.prepared = true
+	}
+
+	return c.commit(ctx, entry, branches)
+}
+
+// begin 在参与者上开启分支事务
+// 参数:
+//   - ctx: 上下文
+//   - p: 参与者
+//   - gtrid: 全局事务ID
+// 返回值:
+//   - *branch: 分支事务
+//   - *gorm.DB: 绑定到分支事务连接的实例
+//   - error: 错误信息
+func (c *Coordinator) begin(ctx context.Context, p *participant, gtrid string) (*branch, *gorm.DB, error) {
+	db := p.manager.GetMasterDB()
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, fmt.Errorf("twophase: failed to get %s connection pool: %w", p.name, err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("twophase: failed to get %s connection: %w", p.name, err)
+	}
+
+	b := &branch{participant: p, xid: xid{gtrid: gtrid, bqual: p.name}, conn: conn}
+	if err := p.dialect.begin(ctx, conn, b.xid); err != nil {
+		b.release()
+		return nil, nil, fmt.Errorf("twophase: failed to begin %s: %w", p.name, err)
+	}
+
+	// 分支事务中不能再开启本地事务，嵌套的Transaction使用保存点
+	tx := db.Session(&gorm.Session{NewDB: true, Context: ctx, SkipDefaultTransaction: true})
+	tx.Statement.ConnPool = &branchConn{Conn: conn}
+	return b, tx, nil
+}
+
+// commit 记录提交决定并提交所有已准备的分支事务
+// 参数:
+//   - ctx: 上下文
+//   - entry: 恢复日志记录
+//   - branches: 已准备的分支事务
+// 返回值:
+//   - error: 错误信息
+func (c *Coordinator) commit(ctx context.Context, entry *LogEntry, branches []*branch) error {
+	ctx = context.WithoutCancel(ctx)
+
+	// 提交决定写入恢复日志后才开始提交，崩溃后由Recover完成提交。
+	// 条件更新与Recover的中止声明互斥：Recover已中止该事务时更新不到记录，
+	// 分支事务交由Recover回滚，这里不再处理，避免重复回滚
+	result := c.log.GetMasterDB().WithContext(ctx).Model(entry).
+		Where("status = ?", StatusPreparing).
+		Update("status", StatusCommitting)
+	if result.Error == nil && result.RowsAffected != 1 {
+		return fmt.Errorf("twophase: transaction %s was aborted by recovery", entry.ID)
+	}
+	if result.Error != nil {
+		// 更新可能已经生效，以日志中的实际状态为准
+		status, err := c.entryStatus(ctx, entry.ID)
+		switch {
+		case err != nil:
+			c.runner.Logger().Error(ctx, "two-phase transaction %s is in doubt: %v", entry.ID, result.Error)
+			return fmt.Errorf("%w: %s: failed to record commit decision: %w", ErrInDoubt, entry.ID, result.Error)
+		case status == StatusPreparing:
+			// 更新未生效，声明中止成功后自行回滚，否则已由Recover接管
+			if claimed, err := c.claimAbort(ctx, entry); err == nil && claimed {
+				c.abort(ctx, entry, branches)
+			}
+			return fmt.Errorf("twophase: failed to record commit decision: %w", result.Error)
+		case status != StatusCommitting:
+			return fmt.Errorf("twophase: transaction %s was aborted by recovery: %w", entry.ID, result.Error)
+		}
+	}
+
+	var errs []error
+	for _, b := range branches {
+		if err := b.participant.dialect.commitPrepared(ctx, b.conn, b.xid); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.participant.name, err))
+			continue
+		}
+		b.done = true
+	}
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		c.runner.Logger().Error(ctx, "two-phase transaction %s is in doubt: %v", entry.ID, err)
+		return fmt.Errorf("%w: %s: %w", ErrInDoubt, entry.ID, err)
+	}
+
+	if err := c.log.GetMasterDB().WithContext(ctx).Delete(entry).Error; err != nil {
+		c.runner.Logger().Warn(ctx, "failed to delete two-phase log entry %s: %v", entry.ID, err)
+	}
+	return nil
+}
+
+// abort 回滚所有分支事务
+// 全部回滚成功时删除恢复日志记录，否则保留记录，由Recover回滚遗留的预提交事务
+// 参数:
+//   - ctx: 上下文
+//   - entry: 恢复日志记录
+//   - branches: 分支事务
+func (c *Coordinator) abort(ctx context.Context, entry *LogEntry, branches []*branch) {
+	ctx = context.WithoutCancel(ctx)
+
+	resolved := true
+	for _, b := range branches {
+		var err error
+		if b.prepared {
+			err = b.participant.dialect.rollbackPrepared(ctx, b.conn, b.xid)
+		} else {
+			err = b.participant.dialect.rollback(ctx, b.conn, b.xid)
+		}
+		if err != nil {
+			resolved = false
+			c.runner.Logger().Error(ctx, "failed to roll back two-phase branch %s: %v", b.xid, err)
+			continue
+		}
+		b.done = true
+	}
+
+	if !resolved {
+		return
+	}
+	if err := c.log.GetMasterDB().WithContext(ctx).Delete(entry).Error; err != nil {
+		c.runner.Logger().Warn(ctx, "failed to delete two-phase log entry %s: %v", entry.ID, err)
+	}
+}
+
+// entryStatus 读取恢复日志记录的状态
+// 参数:
+//   - ctx: 上下文
+//   - id: 全局事务ID
+// 返回值:
+//   - string: 记录的状态，记录不存在时为空
+//   - error: 错误信息
+func (c *Coordinator) entryStatus(ctx context.Context, id string) (string, error) {
+	var entries []LogEntry
+	if err := c.log.GetMasterDB().WithContext(ctx).Where("id = ?", id).Limit(1).Find(&entries).Error; err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", nil
+	}
+	return entries[0].Status, nil
+}
+
+// newID 生成全局事务ID
+// 返回值:
+//   - string: 全局事务ID
+//   - error: 错误信息
+func (c *Coordinator) newID() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("twophase: failed to generate transaction id: %w", err)
+	}
+	return c.config.Prefix + "-" + hex.EncodeToString(buf), nil
+}
+
+// participantNames 返回以逗号分隔的参与者名称
+// 返回值:
+//   - string: 参与者名称
+func (c *Coordinator) participantNames() string {
+	names := make([]string, len(c.participants))
+	for i, p := range c.participants {
+		names[i] = p.name
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,209 @@
+package twophase
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// xid 参与者分支事务的标识
+type xid struct {
+	// gtrid 全局事务ID
+	gtrid string
+	// bqual 分支标识，即参与者名称
+	bqual string
+}
+
+// String 返回分支事务标识，同时作为PostgreSQL的预提交事务ID
+// 返回值:
+//   - string: 分支事务标识
+func (x xid) String() string {
+	return x.gtrid + ":" + x.bqual
+}
+
+// parseXID 解析PostgreSQL的预提交事务ID
+// 参数:
+//   - gid: 预提交事务ID
+// 返回值:
+//   - xid: 分支事务标识
+//   - bool: 是否为本包生成的格式
+func parseXID(gid string) (xid, bool) {
+	i := strings.LastIndexByte(gid, ':')
+	if i <= 0 || i == len(gid)-1 {
+		return xid{}, false
+	}
+	return xid{gtrid: gid[:i], bqual: gid[i+1:]}, true
+}
+
+// sqlConn 执行两阶段提交语句的连接，*sql.Conn和*sql.DB均满足
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// xaDialect 数据库的两阶段提交语句
+// 分支事务的开启、准备和回滚在同一连接上执行；预提交事务与连接无关，可在任意连接上提交或回滚
+type xaDialect interface {
+	// begin 开启分支事务
+	begin(ctx context.Context, conn sqlConn, x xid) error
+	// prepare 准备分支事务，成功后事务持久保存在数据库中，等待提交或回滚
+	prepare(ctx context.Context, conn sqlConn, x xid) error
+	// rollback 回滚未准备的分支事务
+	rollback(ctx context.Context, conn sqlConn, x xid) error
+	// commitPrepared 提交已准备的分支事务
+	commitPrepared(ctx context.Context, conn sqlConn, x xid) error
+	// rollbackPrepared 回滚已准备的分支事务
+	rollbackPrepared(ctx context.Context, conn sqlConn, x xid) error
+	// recover 列出数据库中所有已准备的分支事务
+	recover(ctx context.Context, conn sqlConn) ([]xid, error)
+}
+
+// dialects 支持两阶段提交的方言，键为GORM方言名称
+var dialects = map[string]xaDialect{
+	"mysql":    mysqlXA{},
+	"postgres": postgresXA{},
+}
+
+// mysqlXA MySQL的XA事务
+type mysqlXA struct{}
+
+// xaArgs 返回XA语句中的事务标识，标识只包含安全字符，无需转义
+// 参数:
+//   - x: 分支事务标识
+// 返回值:
+//   - string: XA语句参数
+func (mysqlXA) xaArgs(x xid) string {
+	return fmt.Sprintf("'%s','%s'", x.gtrid, x.bqual)
+}
+
+// begin 执行XA START开启分支事务
+func (d mysqlXA) begin(ctx context.Context, conn sqlConn, x xid) error {
+	_, err := conn.ExecContext(ctx, "XA START "+d.xaArgs(x))
+	return err
+}
+
+// prepare 执行XA END和XA PREPARE准备分支事务
+func (d mysqlXA) prepare(ctx context.Context, conn sqlConn, x xid) error {
+	if _, err := conn.ExecContext(ctx, "XA END "+d.xaArgs(x)); err != nil {
+		return err
+	}
+	_, err := conn.ExecContext(ctx, "XA PREPARE "+d.xaArgs(x))
+	return err
+}
+
+// rollback 结束并回滚未准备的分支事务
+func (d mysqlXA) rollback(ctx context.Context, conn sqlConn, x xid) error {
+	// 准备阶段失败时分支可能已经结束，忽略XA END的错误
+	conn.ExecContext(ctx, "XA END "+d.xaArgs(x))
+	_, err := conn.ExecContext(ctx, "XA ROLLBACK "+d.xaArgs(x))
+	return err
+}
+
+// commitPrepared 执行XA COMMIT提交已准备的分支事务
+func (d mysqlXA) commitPrepared(ctx context.Context, conn sqlConn, x xid) error {
+	_, err := conn.ExecContext(ctx, "XA COMMIT "+d.xaArgs(x))
+	return err
+}
+
+// rollbackPrepared 执行XA ROLLBACK回滚已准备的分支事务
+func (d mysqlXA) rollbackPrepared(ctx context.Context, conn sqlConn, x xid) error {
+	_, err := conn.ExecContext(ctx, "XA ROLLBACK "+d.xaArgs(x))
+	return err
+}
+
+// recover 通过XA RECOVER列出已准备的分支事务
+func (mysqlXA) recover(ctx context.Context, conn sqlConn) ([]xid, error) {
+	rows, err := conn.QueryContext(ctx, "XA RECOVER")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var xids []xid
+	for rows.Next() {
+		var (
+			formatID                 int64
+			gtridLength, bqualLength int
+			data                     []byte
+		)
+		if err := rows.Scan(&formatID, &gtridLength, &bqualLength, &data); err != nil {
+			return nil, err
+		}
+		// 只处理XA START默认格式且长度合法的事务
+		if formatID != 1 || gtridLength+bqualLength != len(data) {
+			continue
+		}
+		xids = append(xids, xid{gtrid: string(data[:gtridLength]), bqual: string(data[gtridLength:])})
+	}
+	return xids, rows.Err()
+}
+
+// postgresXA PostgreSQL的预提交事务，需要max_prepared_transactions大于0
+type postgresXA struct{}
+
+// begin 开启本地事务，准备时转为预提交事务
+func (postgresXA) begin(ctx context.Context, conn sqlConn, x xid) error {
+	_, err := conn.ExecContext(ctx, "BEGIN")
+	return err
+}
+
+// prepare 执行PREPARE TRANSACTION准备分支事务
+func (postgresXA) prepare(ctx context.Context, conn sqlConn, x xid) error {
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PREPARE TRANSACTION '%s'", x)); err != nil {
+		return err
+	}
+
+	// 事务已出错时PREPARE TRANSACTION会回滚事务而不返回错误，需确认预提交事务存在
+	rows, err := conn.QueryContext(ctx, "SELECT gid FROM pg_prepared_xacts WHERE gid = $1", x.String())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("transaction was rolled back instead of prepared")
+	}
+	return nil
+}
+
+// rollback 回滚未准备的本地事务
+func (postgresXA) rollback(ctx context.Context, conn sqlConn, x xid) error {
+	_, err := conn.ExecContext(ctx, "ROLLBACK")
+	return err
+}
+
+// commitPrepared 执行COMMIT PREPARED提交预提交事务
+func (postgresXA) commitPrepared(ctx context.Context, conn sqlConn, x xid) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("COMMIT PREPARED '%s'", x))
+	return err
+}
+
+// rollbackPrepared 执行ROLLBACK PREPARED回滚预提交事务
+func (postgresXA) rollbackPrepared(ctx context.Context, conn sqlConn, x xid) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("ROLLBACK PREPARED '%s'", x))
+	return err
+}
+
+// recover 从pg_prepared_xacts列出当前数据库的预提交事务
+func (postgresXA) recover(ctx context.Context, conn sqlConn) ([]xid, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT gid FROM pg_prepared_xacts WHERE database = current_database()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var xids []xid
+	for rows.Next() {
+		var gid string
+		if err := rows.Scan(&gid); err != nil {
+			return nil, err
+		}
+		if x, ok := parseXID(gid); ok {
+			xids = append(xids, x)
+		}
+	}
+	return xids, rows.Err()
+}
@@ -0,0 +1,48 @@
+package twophase
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 恢复日志中全局事务的状态
+const (
+	// StatusPreparing 正在执行或准备，崩溃后恢复时回滚
+	StatusPreparing = "preparing"
+	// StatusCommitting 所有参与者已准备完成并决定提交，崩溃后恢复时提交
+	StatusCommitting = "committing"
+	// StatusAborting 已被恢复流程中止，不能再提交
+	StatusAborting = "aborting"
+)
+
+// LogEntry 恢复日志表模型
+// 每个进行中的全局事务一条记录，全部参与者提交或回滚后删除
+type LogEntry struct {
+	// ID 全局事务ID
+	ID string `gorm:"primaryKey;size:64"`
+	// Participants 参与者名称，以逗号分隔
+	Participants string `gorm:"size:1024;not null"`
+	// Status 全局事务状态 (preparing, committing, aborting)
+	Status string `gorm:"size:16;not null"`
+	// CreatedAt 创建时间
+	CreatedAt time.Time
+	// UpdatedAt 最近一次状态变更时间，恢复时跳过宽限期内的记录
+	UpdatedAt time.Time `gorm:"index"`
+}
+
+// TableName 返回表名
+// 返回值:
+//   - string: 表名
+func (LogEntry) TableName() string {
+	return "twophase_log"
+}
+
+// Migrate 创建或更新恢复日志表
+// 参数:
+//   - db: 数据库实例
+// 返回值:
+//   - error: 错误信息
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&LogEntry{})
+}
@@ -0,0 +1,232 @@
+package twophase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RecoveryResult 恢复结果
+type RecoveryResult struct {
+	// Committed 已提交的分支事务
+	Committed []string `json:"committed"`
+	// RolledBack 已回滚的分支事务
+	RolledBack []string `json:"rolled_back"`
+	// Pending 仍在宽限期内或处理失败、留待下次恢复的分支事务
+	Pending []string `json:"pending"`
+}
+
+// Start 在恢复日志管理器的生命周期内启动后台恢复
+// 启动时立即执行一次恢复，之后按RecoveryInterval定期执行；管理器关闭时停止
+// 返回值:
+//   - error: 管理器已关闭时返回错误
+func (c *Coordinator) Start() error {
+	return c.runner.Go(c.run)
+}
+
+// run 后台恢复循环
+// 参数:
+//   - ctx: 管理器上下文
+func (c *Coordinator) run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.RecoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := c.Recover(ctx)
+		if err != nil && ctx.Err() == nil {
+			c.runner.Logger().Error(ctx, "two-phase recovery failed: %v", err)
+		}
+		if len(result.Committed) > 0 || len(result.RolledBack) > 0 {
+			c.runner.Logger().Info(ctx, "two-phase recovery committed %d and rolled back %d branches",
+				len(result.Committed), len(result.RolledBack))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recover 处理遗留的预提交事务
+// 恢复日志中已决定提交的事务提交，其余（包括没有日志记录的）回滚；状态在宽限期内变更过的事务视为仍在执行，不做处理。
+// 回滚前先以条件更新将日志记录从preparing改为aborting，与Run记录提交决定互斥，不依赖各主机时钟的一致性。
+// 处理完成的日志记录会被删除。应在启动时调用，或通过Start在后台定期执行
+// 参数:
+//   - ctx: 上下文
+// 返回值:
+//   - RecoveryResult: 恢复结果
+//   - error: 错误信息，部分失败时同时返回已完成的结果
+func (c *Coordinator) Recover(ctx context.Context) (RecoveryResult, error) {
+	var result RecoveryResult
+
+	prefix := c.config.Prefix + "-"
+	var entries []LogEntry
+	if err := c.log.GetMasterDB().WithContext(ctx).Where("id LIKE ?", prefix+"%").Find(&entries).Error; err != nil {
+		return result, fmt.Errorf("twophase: failed to read recovery log: %w", err)
+	}
+
+	// decisions 本次恢复中各全局事务的处理决定
+	decisions := make(map[string]decision)
+	// pending 仍有未处理分支的全局事务，其日志记录需要保留
+	pending := make(map[string]bool)
+	var errs []error
+	for _, p := range c.participants {
+		sqlDB, err := p.manager.GetMasterDB().DB()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+			continue
+		}
+		xids, err := p.dialect.recover(ctx, sqlDB)
+		if err != nil {
+			// 无法确认该参与者的状态，保留所有日志记录
+			errs = append(errs, fmt.Errorf("%s: failed to list prepared transactions: %w", p.name, err))
+			for _, entry := range entries {
+				pending[entry.ID] = true
+			}
+			continue
+		}
+
+		for _, x := range xids {
+			if !strings.HasPrefix(x.gtrid, prefix) || x.bqual != p.name {
+				continue
+			}
+
+			d, ok := decisions[x.gtrid]
+			if !ok {
+				if d, err = c.decide(ctx, x.gtrid); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", x, err))
+				}
+				decisions[x.gtrid] = d
+			}
+
+			switch d {
+			case decisionCommit:
+				err = p.dialect.commitPrepared(ctx, sqlDB, x)
+			case decisionRollback:
+				err = p.dialect.rollbackPrepared(ctx, sqlDB, x)
+			default:
+				pending[x.gtrid] = true
+				result.Pending = append(result.Pending, x.String())
+				continue
+			}
+			switch {
+			case err != nil:
+				pending[x.gtrid] = true
+				result.Pending = append(result.Pending, x.String())
+				errs = append(errs, fmt.Errorf("%s: %w", x, err))
+			case d == decisionCommit:
+				result.Committed = append(result.Committed, x.String())
+			default:
+				result.RolledBack = append(result.RolledBack, x.String())
+			}
+		}
+	}
+
+	// 没有遗留分支且已过宽限期的记录处理完毕
+	for i := range entries {
+		entry := &entries[i]
+		if pending[entry.ID] || time.Since(entry.UpdatedAt) < c.config.RecoveryGrace {
+			continue
+		}
+		if err := c.cleanup(ctx, entry); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete log entry %s: %w", entry.ID, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return result, fmt.Errorf("twophase: recovery incomplete: %w", errors.Join(errs...))
+	}
+	return result, nil
+}
+
+// decision 恢复时对全局事务的处理决定
+type decision int
+
+const (
+	// decisionSkip 仍在执行或无法确定，留待下次恢复
+	decisionSkip decision = iota
+	// decisionCommit 提交
+	decisionCommit
+	// decisionRollback 回滚
+	decisionRollback
+)
+
+// decide 根据恢复日志决定如何处理全局事务的预提交分支
+// preparing状态的记录需先声明中止，声明失败说明Run已记录提交决定或已结束，重新读取后再决定
+// 参数:
+//   - ctx: 上下文
+//   - gtrid: 全局事务ID
+// 返回值:
+//   - decision: 处理决定
+//   - error: 读取或更新日志失败时返回错误，此时决定为decisionSkip
+func (c *Coordinator) decide(ctx context.Context, gtrid string) (decision, error) {
+	for {
+		var entries []LogEntry
+		if err := c.log.GetMasterDB().WithContext(ctx).Where("id = ?", gtrid).Limit(1).Find(&entries).Error; err != nil {
+			return decisionSkip, fmt.Errorf("failed to read log entry: %w", err)
+		}
+		// 记录在分支开启前写入，不存在说明已被删除，遗留的分支只能回滚
+		if len(entries) == 0 {
+			return decisionRollback, nil
+		}
+
+		entry := &entries[0]
+		if time.Since(entry.UpdatedAt) < c.config.RecoveryGrace {
+			return decisionSkip, nil
+		}
+		switch entry.Status {
+		case StatusCommitting:
+			return decisionCommit, nil
+		case StatusAborting:
+			return decisionRollback, nil
+		}
+
+		claimed, err := c.claimAbort(ctx, entry)
+		if err != nil {
+			return decisionSkip, err
+		}
+		if claimed {
+			return decisionRollback, nil
+		}
+	}
+}
+
+// claimAbort 将preparing状态的记录声明为中止
+// 参数:
+//   - ctx: 上下文
+//   - entry: 恢复日志记录
+// 返回值:
+//   - bool: 是否声明成功，记录已不是preparing状态时为false
+//   - error: 错误信息
+func (c *Coordinator) claimAbort(ctx context.Context, entry *LogEntry) (bool, error) {
+	result := c.log.GetMasterDB().WithContext(ctx).Model(entry).
+		Where("status = ?", StatusPreparing).
+		Update("status", StatusAborting)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to abort log entry: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// cleanup 删除没有遗留分支的日志记录
+// preparing状态的记录先声明中止再删除，删除时要求状态未变，避免误删Run刚记录的提交决定
+// 参数:
+//   - ctx: 上下文
+//   - entry: 恢复日志记录
+// 返回值:
+//   - error: 错误信息
+func (c *Coordinator) cleanup(ctx context.Context, entry *LogEntry) error {
+	status := entry.Status
+	if status == StatusPreparing {
+		claimed, err := c.claimAbort(ctx, entry)
+		if err != nil || !claimed {
+			return err
+		}
+		status = StatusAborting
+	}
+	return c.log.GetMasterDB().WithContext(ctx).Where("status = ?", status).Delete(entry).Error
+}
@@ -0,0 +1,462 @@
+package twophase
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+)
+
+// fakeServer 模拟支持两阶段提交的数据库，记录执行的语句和已准备的分支事务
+type fakeServer struct {
+	mu         sync.Mutex
+	statements []string
+	prepared   map[string]xid
+	// failPrepare 和 failCommit 为非空时，准备或提交该分支事务失败
+	failPrepare string
+	failCommit  string
+}
+
+// newFakeServer 创建模拟数据库
+func newFakeServer() *fakeServer {
+	return &fakeServer{prepared: make(map[string]xid)}
+}
+
+// xidPattern 匹配语句中的事务标识
+var xidPattern = regexp.MustCompile(`'([^']*)'`)
+
+// statementXID 解析语句中的事务标识
+func statementXID(query string) xid {
+	matches := xidPattern.FindAllStringSubmatch(query, -1)
+	if len(matches) == 2 {
+		return xid{gtrid: matches[0][1], bqual: matches[1][1]}
+	}
+	if len(matches) == 1 {
+		x, _ := parseXID(matches[0][1])
+		return x
+	}
+	return xid{}
+}
+
+// exec 执行语句
+func (s *fakeServer) exec(query string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements = append(s.statements, query)
+
+	x := statementXID(query)
+	switch {
+	case strings.HasPrefix(query, "XA PREPARE"), strings.HasPrefix(query, "PREPARE TRANSACTION"):
+		if x.bqual == s.failPrepare {
+			return errors.New("prepare failed")
+		}
+		s.prepared[x.String()] = x
+	case strings.HasPrefix(query, "XA COMMIT"), strings.HasPrefix(query, "COMMIT PREPARED"):
+		if x.bqual == s.failCommit {
+			return driver.ErrBadConn
+		}
+		if _, ok := s.prepared[x.String()]; !ok {
+			return fmt.Errorf("unknown xid %s", x)
+		}
+		delete(s.prepared, x.String())
+	case strings.HasPrefix(query, "ROLLBACK PREPARED"):
+		if _, ok := s.prepared[x.String()]; !ok {
+			return fmt.Errorf("unknown xid %s", x)
+		}
+		delete(s.prepared, x.String())
+	case strings.HasPrefix(query, "XA ROLLBACK"):
+		delete(s.prepared, x.String())
+	}
+	return nil
+}
+
+// query 执行查询
+func (s *fakeServer) query(query string, args []driver.NamedValue) *fakeRows {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements = append(s.statements, query)
+
+	rows := &fakeRows{}
+	switch {
+	case query == "XA RECOVER":
+		rows.columns = []string{"formatID", "gtrid_length", "bqual_length", "data"}
+		for _, x := range s.prepared {
+			rows.values = append(rows.values, []driver.Value{int64(1), int64(len(x.gtrid)), int64(len(x.bqual)), []byte(x.gtrid + x.bqual)})
+		}
+	case strings.HasPrefix(query, "SELECT gid FROM pg_prepared_xacts"):
+		rows.columns = []string{"gid"}
+		for gid := range s.prepared {
+			if len(args) == 0 || args[0].Value == gid {
+				rows.values = append(rows.values, []driver.Value{gid})
+			}
+		}
+	}
+	return rows
+}
+
+// executed 返回执行过的语句
+func (s *fakeServer) executed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// preparedXIDs 返回已准备的分支事务
+func (s *fakeServer) preparedXIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var gids []string
+	for gid := range s.prepared {
+		gids = append(gids, gid)
+	}
+	return gids
+}
+
+// fakeConnector 模拟数据库的驱动连接器
+type fakeConnector struct{ server *fakeServer }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{server: c.server}, nil }
+func (c fakeConnector) Driver() driver.Driver                         { return nil }
+
+// fakeConn 模拟数据库连接
+type fakeConn struct{ server *fakeServer }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("local transactions are not supported") }
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.server.exec(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.server.query(query, args), nil
+}
+
+// fakeRows 模拟查询结果
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// fakeManager 只提供主库实例的管理器
+type fakeManager struct {
+	database.Manager
+	db *gorm.DB
+}
+
+func (m fakeManager) GetMasterDB() *gorm.DB { return m.db }
+
+// newFakeManager 创建使用模拟数据库的管理器
+func newFakeManager(t *testing.T, dialect string, server *fakeServer) database.Manager {
+	t.Helper()
+
+	sqlDB := sql.OpenDB(fakeConnector{server: server})
+	t.Cleanup(func() { sqlDB.Close() })
+
+	var dialector gorm.Dialector
+	if dialect == "mysql" {
+		dialector = mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true})
+	} else {
+		dialector = postgres.New(postgres.Config{Conn: sqlDB})
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	return fakeManager{db: db}
+}
+
+// newReplicatedManager 创建配置了从库的MySQL模拟管理器
+func newReplicatedManager(t *testing.T, master, replica *fakeServer) database.Manager {
+	t.Helper()
+
+	manager := newFakeManager(t, "mysql", master).(fakeManager)
+	replicaDB := sql.OpenDB(fakeConnector{server: replica})
+	t.Cleanup(func() { replicaDB.Close() })
+	require.NoError(t, manager.db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{mysql.New(mysql.Config{Conn: replicaDB, SkipInitializeWithVersion: true})},
+	})))
+	return manager
+}
+
+// newLogManager 创建保存恢复日志的SQLite管理器
+func newLogManager(t *testing.T) database.Manager {
+	t.Helper()
+
+	config := database.DefaultConfig()
+	config.Type = "sqlite"
+	config.Master = filepath.Join(t.TempDir(), "log.db")
+	config.LogConfig.Level = "silent"
+	config.PoolConfig = database.PoolConfig{MaxOpenConns: 1}
+
+	manager, err := database.NewManager(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.Close() })
+	require.NoError(t, Migrate(manager.GetDB()))
+	return manager
+}
+
+// logEntries 返回恢复日志记录
+func logEntries(t *testing.T, log database.Manager) []LogEntry {
+	t.Helper()
+
+	var entries []LogEntry
+	require.NoError(t, log.GetDB().Find(&entries).Error)
+	return entries
+}
+
+func TestNewCoordinator(t *testing.T) {
+	log := newLogManager(t)
+
+	_, err := NewCoordinator(log, map[string]database.Manager{"log": log}, Config{})
+	assert.ErrorIs(t, err, ErrNotSupported)
+	assert.ErrorContains(t, err, "sqlite")
+
+	orders := newFakeManager(t, "mysql", newFakeServer())
+	_, err = NewCoordinator(log, map[string]database.Manager{"orders:1": orders}, Config{})
+	assert.ErrorContains(t, err, "invalid participant name")
+	_, err = NewCoordinator(log, map[string]database.Manager{"orders": orders}, Config{Prefix: "a'b"})
+	assert.ErrorContains(t, err, "invalid prefix")
+	_, err = NewCoordinator(log, nil, Config{})
+	assert.Error(t, err)
+	_, err = NewCoordinator(nil, map[string]database.Manager{"orders": orders}, Config{})
+	assert.Error(t, err)
+	_, err = NewCoordinator(orders, map[string]database.Manager{"orders": orders}, Config{})
+	assert.ErrorContains(t, err, "does not support background tasks")
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	log := newLogManager(t)
+	mysqlServer, pgServer := newFakeServer(), newFakeServer()
+	coordinator, err := NewCoordinator(log, map[string]database.Manager{
+		"orders":   newFakeManager(t, "mysql", mysqlServer),
+		"payments": newFakeManager(t, "postgres", pgServer),
+	}, Config{})
+	require.NoError(t, err)
+
+	update := func(txs map[string]*gorm.DB) error {
+		if err := txs["orders"].Exec("UPDATE orders SET paid = 1").Error; err != nil {
+			return err
+		}
+		return txs["payments"].Exec("UPDATE payments SET settled = 1").Error
+	}
+
+	t.Run("提交", func(t *testing.T) {
+		require.NoError(t, coordinator.Run(ctx, update))
+
+		statements := mysqlServer.executed()
+		require.Len(t, statements, 5)
+		x := statementXID(statements[0])
+		assert.True(t, strings.HasPrefix(x.gtrid, "tpc-"))
+		assert.Equal(t, "orders", x.bqual)
+		args := fmt.Sprintf("'%s','orders'", x.gtrid)
+		assert.Equal(t, []string{
+			"XA START " + args,
+			"UPDATE orders SET paid = 1",
+			"XA END " + args,
+			"XA PREPARE " + args,
+			"XA COMMIT " + args,
+		}, statements)
+
+		gid := x.gtrid + ":payments"
+		assert.Equal(t, []string{
+			"BEGIN",
+			"UPDATE payments SET settled = 1",
+			"PREPARE TRANSACTION '" + gid + "'",
+			"SELECT gid FROM pg_prepared_xacts WHERE gid = $1",
+			"COMMIT PREPARED '" + gid + "'",
+		}, pgServer.executed())
+		assert.Empty(t, logEntries(t, log))
+	})
+
+	t.Run("fn返回错误时回滚", func(t *testing.T) {
+		errFailed := errors.New("失败")
+		err := coordinator.Run(ctx, func(txs map[string]*gorm.DB) error {
+			return errFailed
+		})
+		assert.Equal(t, errFailed, err)
+
+		statements := mysqlServer.executed()
+		assert.True(t, strings.HasPrefix(statements[len(statements)-1], "XA ROLLBACK"))
+		statements = pgServer.executed()
+		assert.Equal(t, "ROLLBACK", statements[len(statements)-1])
+		assert.Empty(t, logEntries(t, log))
+	})
+
+	t.Run("准备失败时回滚已准备的参与者", func(t *testing.T) {
+		pgServer.failPrepare = "payments"
+		defer func() { pgServer.failPrepare = "" }()
+
+		err := coordinator.Run(ctx, update)
+		assert.ErrorContains(t, err, "failed to prepare payments")
+
+		statements := mysqlServer.executed()
+		assert.True(t, strings.HasPrefix(statements[len(statements)-2], "XA PREPARE"))
+		assert.True(t, strings.HasPrefix(statements[len(statements)-1], "XA ROLLBACK"))
+		assert.Empty(t, mysqlServer.preparedXIDs())
+		assert.Empty(t, logEntries(t, log))
+	})
+}
+
+func TestRunWithReplica(t *testing.T) {
+	ctx := context.Background()
+	master, replica := newFakeServer(), newFakeServer()
+	coordinator, err := NewCoordinator(newLogManager(t), map[string]database.Manager{
+		"orders": newReplicatedManager(t, master, replica),
+	}, Config{})
+	require.NoError(t, err)
+
+	// 分支事务中的读写都不能被读写分离切换到其他连接
+	err = coordinator.Run(ctx, func(txs map[string]*gorm.DB) error {
+		var count int64
+		if err := txs["orders"].Raw("SELECT count(*) FROM orders").Scan(&count).Error; err != nil {
+			return err
+		}
+		return txs["orders"].Exec("UPDATE orders SET paid = 1").Error
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, replica.executed())
+	statements := master.executed()
+	require.Len(t, statements, 6)
+	assert.True(t, strings.HasPrefix(statements[0], "XA START"))
+	assert.Equal(t, "SELECT count(*) FROM orders", statements[1])
+	assert.Equal(t, "UPDATE orders SET paid = 1", statements[2])
+	assert.True(t, strings.HasPrefix(statements[5], "XA COMMIT"))
+
+	// 分支事务只能由协调器提交
+	err = coordinator.Run(ctx, func(txs map[string]*gorm.DB) error {
+		return txs["orders"].Commit().Error
+	})
+	assert.ErrorContains(t, err, "committed by the coordinator")
+}
+
+func TestRecoverFencesRun(t *testing.T) {
+	ctx := context.Background()
+	log := newLogManager(t)
+	server := newFakeServer()
+	coordinator, err := NewCoordinator(log, map[string]database.Manager{
+		"orders": newFakeManager(t, "mysql", server),
+	}, Config{RecoveryGrace: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	// 执行超过宽限期时被恢复流程中止，Run不能再提交
+	err = coordinator.Run(ctx, func(txs map[string]*gorm.DB) error {
+		time.Sleep(60 * time.Millisecond)
+		_, err := coordinator.Recover(ctx)
+		return err
+	})
+	assert.ErrorContains(t, err, "aborted by recovery")
+
+	// 失去提交权后Run不再处理分支事务，预提交的分支留给Recover回滚
+	for _, statement := range server.executed() {
+		assert.False(t, strings.HasPrefix(statement, "XA COMMIT"), statement)
+		assert.False(t, strings.HasPrefix(statement, "XA ROLLBACK"), statement)
+	}
+	prepared := server.preparedXIDs()
+	require.Len(t, prepared, 1)
+	assert.Empty(t, logEntries(t, log))
+
+	result, err := coordinator.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, prepared, result.RolledBack)
+	assert.Empty(t, server.preparedXIDs())
+}
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	log := newLogManager(t)
+	mysqlServer, pgServer := newFakeServer(), newFakeServer()
+	coordinator, err := NewCoordinator(log, map[string]database.Manager{
+		"orders":   newFakeManager(t, "mysql", mysqlServer),
+		"payments": newFakeManager(t, "postgres", pgServer),
+	}, Config{RecoveryGrace: 200 * time.Millisecond})
+	require.NoError(t, err)
+
+	// 提交决定已记录，但payments提交时连接中断
+	pgServer.failCommit = "payments"
+	err = coordinator.Run(ctx, func(txs map[string]*gorm.DB) error { return nil })
+	assert.ErrorIs(t, err, ErrInDoubt)
+	pgServer.failCommit = ""
+
+	entries := logEntries(t, log)
+	require.Len(t, entries, 1)
+	assert.Equal(t, StatusCommitting, entries[0].Status)
+	assert.Equal(t, "orders,payments", entries[0].Participants)
+	inDoubt := entries[0].ID + ":payments"
+	assert.Equal(t, []string{inDoubt}, pgServer.preparedXIDs())
+
+	// 没有日志记录的遗留事务回滚，其他前缀的事务不处理
+	orphan := xid{gtrid: "tpc-orphan", bqual: "orders"}
+	other := xid{gtrid: "other-1", bqual: "orders"}
+	mysqlServer.prepared[orphan.String()] = orphan
+	mysqlServer.prepared[other.String()] = other
+
+	// 宽限期内的事务视为仍在执行
+	result, err := coordinator.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{inDoubt}, result.Pending)
+	assert.Equal(t, []string{orphan.String()}, result.RolledBack)
+	assert.Len(t, logEntries(t, log), 1)
+
+	time.Sleep(250 * time.Millisecond)
+	result, err = coordinator.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{inDoubt}, result.Committed)
+	assert.Empty(t, result.Pending)
+	assert.Empty(t, pgServer.preparedXIDs())
+	assert.Equal(t, []string{other.String()}, mysqlServer.preparedXIDs())
+	assert.Empty(t, logEntries(t, log))
+}
+
+func TestCoordinatorLifecycle(t *testing.T) {
+	log := newLogManager(t)
+	server := newFakeServer()
+	coordinator, err := NewCoordinator(log, map[string]database.Manager{
+		"orders": newFakeManager(t, "mysql", server),
+	}, Config{RecoveryInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	orphan := xid{gtrid: "tpc-orphan", bqual: "orders"}
+	server.prepared[orphan.String()] = orphan
+
+	require.NoError(t, coordinator.Start())
+	assert.Eventually(t, func() bool {
+		return len(server.preparedXIDs()) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, log.Close())
+	assert.Error(t, coordinator.Start())
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 事务结果
+const (
+	// TxOutcomeCommitted 已提交
+	TxOutcomeCommitted = "committed"
+	// TxOutcomeRolledBack 已回滚，包括fn返回错误和提交失败
+	TxOutcomeRolledBack = "rolled_back"
+	// TxOutcomePanicked fn panic后回滚
+	TxOutcomePanicked = "panicked"
+	// TxOutcomeCommitUnknown 提交时连接中断，无法确定是否已提交
+	TxOutcomeCommitUnknown = "commit_unknown"
+)
+
+// txDurationBounds 事务时长直方图的桶上界
+var txDurationBounds = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// TransactionStats 事务统计信息
+// 计数均为管理器创建以来的累计值，只统计最外层事务，嵌套的保存点计入外层事务
+type TransactionStats struct {
+	// Begun 成功开启的事务数
+	Begun int64 `json:"begun"`
+	// Committed 提交成功的事务数
+	Committed int64 `json:"committed"`
+	// RolledBack 回滚的事务数，包括fn返回错误和提交失败，不包括panic
+	RolledBack int64 `json:"rolled_back"`
+	// Panicked fn panic后回滚的事务数
+	Panicked int64 `json:"panicked"`
+	// CommitUnknown 提交结果未知的事务数
+	CommitUnknown int64 `json:"commit_unknown"`
+	// Retries 因可重试错误重新执行的次数
+	Retries int64 `json:"retries"`
+	// Timeouts 因超时被取消的事务数
+	Timeouts int64 `json:"timeouts"`
+	// Active 进行中的事务数
+	Active int `json:"active"`
+	// Statements 已结束的事务中执行的SQL语句总数
+	Statements int64 `json:"statements"`
+	// TotalDuration 已结束的事务的总时长
+	TotalDuration time.Duration `json:"total_duration"`
+	// Count 已结束的事务数，即时长直方图的总数（+Inf桶），包括超过最大上界的事务
+	Count int64 `json:"count"`
+	// DurationBuckets 事务时长直方图，计数为累计值，即时长小于等于上界的事务数；超过最大上界的事务只计入Count
+	DurationBuckets []DurationBucket `json:"duration_buckets"`
+}
+
+// DurationBucket 时长直方图的桶
+type DurationBucket struct {
+	// UpperBound 桶上界
+	UpperBound time.Duration `json:"le"`
+	// Count 时长小于等于上界的事务数
+	Count int64 `json:"count"`
+}
+
+// TransactionEvent 事务结束事件
+type TransactionEvent struct {
+	// TransactionInfo 事务信息，不包含调用栈
+	TransactionInfo
+	// Outcome 事务结果 (committed, rolled_back, panicked, commit_unknown)
+	Outcome string `json:"outcome"`
+	// Err 回滚或提交失败的原因，提交成功和panic时为nil
+	Err error `json:"-"`
+}
+
+// txMetrics 事务计数器
+type txMetrics struct {
+	// begun 成功开启的事务数
+	begun atomic.Int64
+	// active 进行中的事务数
+	active atomic.Int64
+	// committed 提交成功的事务数
+	committed atomic.Int64
+	// rolledBack 回滚的事务数
+	rolledBack atomic.Int64
+	// panicked panic后回滚的事务数
+	panicked atomic.Int64
+	// commitUnknown 提交结果未知的事务数
+	commitUnknown atomic.Int64
+	// retries 重试次数
+	retries atomic.Int64
+	// timeouts 超时的事务数
+	timeouts atomic.Int64
+	// statements 已结束事务的语句总数
+	statements atomic.Int64
+	// totalDuration 已结束事务的总时长（纳秒）
+	totalDuration atomic.Int64
+	// buckets 各时长区间的事务数，最后一个元素对应超过最大上界的事务
+	buckets [len(txDurationBounds) + 1]atomic.Int64
+}
+
+// observe 记录已结束的事务
+// 参数:
+//   - outcome: 事务结果
+//   - duration: 事务时长
+//   - statements: 执行的语句数
+//   - err: 回滚原因
+func (s *txMetrics) observe(outcome string, duration time.Duration, statements int64, err error) {
+	switch outcome {
+	case TxOutcomeCommitted:
+		s.committed.Add(1)
+	case TxOutcomeRolledBack:
+		s.rolledBack.Add(1)
+	case TxOutcomePanicked:
+		s.panicked.Add(1)
+	case TxOutcomeCommitUnknown:
+		s.commitUnknown.Add(1)
+	}
+	if errors.Is(err, ErrTransactionTimeout) {
+		s.timeouts.Add(1)
+	}
+	s.statements.Add(statements)
+	s.totalDuration.Add(int64(duration))
+
+	i := 0
+	for i < len(txDurationBounds) && duration > txDurationBounds[i] {
+		i++
+	}
+	s.buckets[i].Add(1)
+}
+
+// TransactionStats 获取事务统计信息
+// 返回值:
+//   - TransactionStats: 事务统计信息
+func (m *DBManager) TransactionStats() TransactionStats {
+	s := &m.txMetrics
+	stats := TransactionStats{
+		Begun:           s.begun.Load(),
+		Committed:       s.committed.Load(),
+		RolledBack:      s.rolledBack.Load(),
+		Panicked:        s.panicked.Load(),
+		CommitUnknown:   s.commitUnknown.Load(),
+		Retries:         s.retries.Load(),
+		Timeouts:        s.timeouts.Load(),
+		Active:          int(s.active.Load()),
+		Statements:      s.statements.Load(),
+		TotalDuration:   time.Duration(s.totalDuration.Load()),
+		DurationBuckets: make([]DurationBucket, len(txDurationBounds)),
+	}
+
+	var cumulative int64
+	for i, bound := range txDurationBounds {
+		cumulative += s.buckets[i].Load()
+		stats.DurationBuckets[i] = DurationBucket{UpperBound: bound, Count: cumulative}
+	}
+	stats.Count = cumulative + s.buckets[len(txDurationBounds)].Load()
+	return stats
+}
+
+// registerTxCallbacks 注册统计事务内语句数的回调
+// 参数:
+//   - db: 数据库实例
+// 返回值:
+//   - error: 注册错误
+func registerTxCallbacks(db *gorm.DB) error {
+	const name = "database:tx_statements"
+	count := func(tx *gorm.DB) {
+		if value, ok := tx.Statement.Context.Value(txKey{}).(*txValue); ok {
+			value.root().countStatement()
+		}
+	}
+
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("*").Register(name, count); err != nil {
+		return err
+	}
+	if err := callbacks.Query().After("*").Register(name, count); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("*").Register(name, count); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("*").Register(name, count); err != nil {
+		return err
+	}
+	if err := callbacks.Row().After("*").Register(name, count); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("*").Register(name, count)
+}